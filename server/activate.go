@@ -1,15 +1,13 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
 
 	"github.com/mattermost/mattermost-server/v5/model"
 )
 
 // OnActivate activate pluguin
 func (p *Plugin) OnActivate() error {
-	p.addCronFunc()
-
 	bot := &model.Bot{
 		Username:    "gather-users",
 		DisplayName: "gatherUsers",
@@ -22,75 +20,30 @@ func (p *Plugin) OnActivate() error {
 
 	p.botUserID = botUserID
 
-	// Deserialize user data
-	userData, err := p.API.KVGet("users")
-	if err != nil {
+	if err := p.migrate(); err != nil {
 		return err
 	}
-	if userData != nil {
-		var users []string
-		err := json.Unmarshal(userData, &users)
-		if err != nil {
-			p.users = []string{}
-		} else {
-			p.users = users
-		}
-	}
 
-	// Deserialize paused data
-	pausedData, err := p.API.KVGet("paused")
-	if err != nil {
-		return err
-	}
-	if pausedData != nil {
-		var paused []string
-		err := json.Unmarshal(pausedData, &paused)
-		if err != nil {
-			p.paused = []string{}
-		} else {
-			p.paused = paused
-		}
-	}
+	store := newKVStore(p.API)
 
-	// Deserialize meetings data
-	meetingsData, err := p.API.KVGet("meetings")
+	state, err := store.Load()
 	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to load the state: %s", err.Error()))
 		return err
 	}
 
-	p.usersMeetings = make(map[string][]string)
+	p.state = newStateManager(store, state)
 
-	if meetingsData != nil {
-		meetings := make(map[string][]string)
-		err := json.Unmarshal(meetingsData, &meetings)
-		if err != nil {
-			p.usersMeetings = make(map[string][]string)
-		} else {
-			p.usersMeetings = meetings
-		}
-	}
-
-	// Deserialize oddUserTurn data
-	oddUserTurnData, err := p.API.KVGet("oddUserTurn")
-	if err != nil {
-		return err
-	}
-
-	p.oddUserTurn = []string{}
+	p.addCronFunc()
 
-	if oddUserTurnData != nil {
-		oddUserTurn := []string{}
-		err := json.Unmarshal(oddUserTurnData, &oddUserTurn)
-		if err != nil {
-			p.oddUserTurn = []string{}
-		} else {
-			p.oddUserTurn = oddUserTurn
-		}
-	}
+	go func() {
+		p.syncChannelPrograms()
+		p.catchUpMissedRounds()
+	}()
 
 	return p.API.RegisterCommand(&model.Command{
 		Trigger:          "gather-plugin",
 		AutoComplete:     true,
-		AutoCompleteDesc: "Available commands: on, off, pause",
+		AutoCompleteDesc: "Available commands: on, off, pause, skip, frequency, last, info, program",
 	})
 }
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+// apiPrefix is where the REST API is served, under /plugins/gather-users
+const apiPrefix = "/api/v1/"
+
+// apiRequest is a request to the REST API made by an authenticated user
+type apiRequest struct {
+	w      http.ResponseWriter
+	r      *http.Request
+	userID string
+	// path is the request path without apiPrefix, split by /
+	path []string
+}
+
+// participantResponse is a user of a program
+type participantResponse struct {
+	UserID     string       `json:"user_id"`
+	Paused     bool         `json:"paused"`
+	Frequency  string       `json:"frequency"`
+	LastPaired *PairedRound `json:"last_paired,omitempty"`
+
+	// PausedUntil and SkipRounds are set if the pause of the user ends by itself
+	PausedUntil int64 `json:"paused_until,omitempty"`
+	SkipRounds  int   `json:"skip_rounds,omitempty"`
+}
+
+// newParticipantResponse returns the participation of the user in the program
+func newParticipantResponse(program *Program, userID string) participantResponse {
+	frequency, ok := program.Frequencies[userID]
+	if !ok {
+		frequency = frequencyAlways
+	}
+
+	paused := program.IsPaused(userID, time.Now())
+	response := participantResponse{
+		UserID:     userID,
+		Paused:     paused,
+		Frequency:  frequency,
+		LastPaired: program.LastPaired[userID],
+	}
+
+	if paused {
+		response.PausedUntil = program.PausedUntil[userID]
+		response.SkipRounds = program.SkipRounds[userID]
+	}
+
+	return response
+}
+
+// meetingResponse is a meeting of the history of a program
+type meetingResponse struct {
+	RoundID        string                      `json:"round_id"`
+	Timestamp      int64                       `json:"timestamp"`
+	Participants   []string                    `json:"participants"`
+	ChannelID      string                      `json:"channel_id"`
+	PostID         string                      `json:"post_id"`
+	FollowUpAt     int64                       `json:"follow_up_at,omitempty"`
+	FollowUpPostID string                      `json:"follow_up_post_id,omitempty"`
+	Feedback       map[string]*MeetingFeedback `json:"feedback,omitempty"`
+}
+
+// newMeetingResponses returns the meetings of the history
+func newMeetingResponses(history History) []*meetingResponse {
+	meetings := []*meetingResponse{}
+
+	for _, record := range history {
+		meetings = append(meetings, &meetingResponse{
+			RoundID:        record.RoundID,
+			Timestamp:      record.Timestamp,
+			Participants:   record.Participants,
+			ChannelID:      record.ChannelID,
+			PostID:         record.PostID,
+			FollowUpAt:     record.FollowUpAt,
+			FollowUpPostID: record.FollowUpPostID,
+			Feedback:       record.Feedback,
+		})
+	}
+
+	return meetings
+}
+
+// oddResponse is the odd user policy of a program and the order in which users sit out
+type oddResponse struct {
+	Policy      string   `json:"policy"`
+	OddUser     string   `json:"odd_user"`
+	OddUserTurn []string `json:"odd_user_turn"`
+}
+
+// ServeHTTP serves the REST API, the Mattermost server authenticates the users
+func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		writeError(w, http.StatusUnauthorized, "Not authorized.")
+		return
+	}
+
+	p.serveAPI(&apiRequest{
+		w:      w,
+		r:      r,
+		userID: userID,
+		path:   strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/"),
+	})
+}
+
+// serveAPI routes the request, every route but the list of programs, the block rules, the tags, the
+// export, the import and the actions of the posts belongs to a program:
+//
+//	POST   /actions/run                                         the buttons of the run confirmation
+//	POST   /actions/confirm                                     the buttons of the round invitations
+//	POST   /actions/feedback                                    the buttons of the meeting follow-ups
+//	GET    /blocks
+//	POST   /blocks                                              {"user_id": "..."} or {"users": ["...", "..."]}
+//	DELETE /blocks/{id}
+//	GET    /tags
+//	PUT    /tags/{user_id}                                      ["...", "..."]
+//	GET    /export?format=json|csv
+//	POST   /import?mode=merge|replace&format=json|csv           the export
+//	GET    /programs
+//	GET    /programs/{program}/participants
+//	POST   /programs/{program}/participants                     {"user_id": "..."}
+//	DELETE /programs/{program}/participants/{user_id}
+//	POST   /programs/{program}/participants/{user_id}/pause     {"until": 1700000000000} or {"rounds": 2}, optional
+//	DELETE /programs/{program}/participants/{user_id}/pause
+//	PUT    /programs/{program}/participants/{user_id}/frequency {"frequency": "weekly"}
+//	GET    /programs/{program}/history
+//	PUT    /programs/{program}/history                          [{"round_id": "...", ...}]
+//	GET    /programs/{program}/odd
+//	POST   /programs/{program}/rounds
+//	GET    /programs/{program}/preview
+//	GET    /programs/{program}/stats
+func (p *Plugin) serveAPI(req *apiRequest) {
+	path := req.path
+	method := req.r.Method
+
+	if strings.Join(path, "/") == runActionPath {
+		p.apiRunAction(req)
+		return
+	}
+
+	if strings.Join(path, "/") == confirmActionPath {
+		p.apiConfirmAction(req)
+		return
+	}
+
+	if strings.Join(path, "/") == feedbackActionPath {
+		p.apiFeedbackAction(req)
+		return
+	}
+
+	if path[0] == "blocks" {
+		p.serveBlocksAPI(req)
+		return
+	}
+
+	if path[0] == "tags" {
+		p.serveTagsAPI(req)
+		return
+	}
+
+	if path[0] == "export" {
+		p.serveExportAPI(req)
+		return
+	}
+
+	if path[0] == "import" {
+		p.serveImportAPI(req)
+		return
+	}
+
+	if len(path) == 0 || path[0] != "programs" {
+		writeError(req.w, http.StatusNotFound, "Not found.")
+		return
+	}
+
+	if len(path) == 1 {
+		if method != http.MethodGet {
+			writeError(req.w, http.StatusMethodNotAllowed, "Method not allowed.")
+			return
+		}
+
+		writeJSON(req.w, http.StatusOK, p.state.Get().ProgramNames())
+		return
+	}
+
+	program, ok := p.getProgram(path[1])
+	if !ok {
+		writeError(req.w, http.StatusNotFound, fmt.Sprintf("The program %s doesn't exist.", path[1]))
+		return
+	}
+
+	route := method + " " + strings.Join(path[2:], "/")
+	if len(path) >= 4 && path[2] == "participants" {
+		// the user id is a parameter of the participant routes
+		route = method + " participants/" + strings.Join(append([]string{"{user_id}"}, path[4:]...), "/")
+	}
+
+	switch route {
+	case "GET participants":
+		p.apiGetParticipants(req, program)
+	case "POST participants":
+		p.apiAddParticipant(req, program)
+	case "DELETE participants/{user_id}":
+		p.apiRemoveParticipant(req, program, path[3])
+	case "POST participants/{user_id}/pause":
+		p.apiPause(req, program, path[3])
+	case "DELETE participants/{user_id}/pause":
+		p.apiUnpause(req, program, path[3])
+	case "PUT participants/{user_id}/frequency":
+		p.apiSetFrequency(req, program, path[3])
+	case "GET history":
+		p.apiGetHistory(req, program)
+	case "PUT history":
+		p.apiReplaceHistory(req, program)
+	case "GET odd":
+		p.apiGetOdd(req, program)
+	case "POST rounds":
+		p.apiRunRound(req, program)
+	case "GET stats":
+		p.apiGetStats(req, program)
+	case "GET preview":
+		p.apiPreview(req, program)
+	default:
+		writeError(req.w, http.StatusNotFound, "Not found.")
+	}
+}
+
+// isAdmin returns true if the user of the request is a system admin
+func (p *Plugin) isAdmin(req *apiRequest) bool {
+	user, err := p.API.GetUser(req.userID)
+	if err != nil {
+		return false
+	}
+
+	return user.IsSystemAdmin()
+}
+
+// requireAdmin writes a forbidden error if the user of the request is not a system admin
+func (p *Plugin) requireAdmin(req *apiRequest) bool {
+	if !p.isAdmin(req) {
+		writeError(req.w, http.StatusForbidden, "Only system admins can do this.")
+		return false
+	}
+
+	return true
+}
+
+// requireSelfOrAdmin writes a forbidden error unless the user of the request is userID or a system admin
+func (p *Plugin) requireSelfOrAdmin(req *apiRequest, userID string) bool {
+	if req.userID == userID {
+		return true
+	}
+
+	return p.requireAdmin(req)
+}
+
+// requireUnboundProgram writes a conflict error if the users of the program are the members of a channel
+func requireUnboundProgram(req *apiRequest, program *Program) bool {
+	if program.ChannelID != "" {
+		writeError(req.w, http.StatusConflict, fmt.Sprintf("The users of the program %s are the members of its channel.", program.Name))
+		return false
+	}
+
+	return true
+}
+
+func (p *Plugin) apiGetParticipants(req *apiRequest, program *Program) {
+	if !p.getConfiguration().AllowInfoForEveryone && !p.requireAdmin(req) {
+		return
+	}
+
+	participants := []participantResponse{}
+	for _, userID := range program.Users {
+		participants = append(participants, newParticipantResponse(program, userID))
+	}
+
+	writeJSON(req.w, http.StatusOK, participants)
+}
+
+func (p *Plugin) apiAddParticipant(req *apiRequest, program *Program) {
+	body := struct {
+		UserID string `json:"user_id"`
+	}{}
+
+	if err := json.NewDecoder(req.r.Body).Decode(&body); err != nil || body.UserID == "" {
+		writeError(req.w, http.StatusBadRequest, "The body must be {\"user_id\": \"...\"}.")
+		return
+	}
+
+	if !p.requireSelfOrAdmin(req, body.UserID) || !requireUnboundProgram(req, program) {
+		return
+	}
+
+	if _, err := p.API.GetUser(body.UserID); err != nil {
+		writeError(req.w, http.StatusBadRequest, fmt.Sprintf("The user %s doesn't exist.", body.UserID))
+		return
+	}
+
+	if err := p.addUser(program.Name, body.UserID); err != nil {
+		writeError(req.w, http.StatusInternalServerError, "Failed to save the participants.")
+		return
+	}
+
+	writeJSON(req.w, http.StatusOK, participantResponse{
+		UserID: body.UserID,
+		Paused: program.IsPaused(body.UserID, time.Now()),
+	})
+}
+
+func (p *Plugin) apiRemoveParticipant(req *apiRequest, program *Program, userID string) {
+	if !p.requireSelfOrAdmin(req, userID) || !requireUnboundProgram(req, program) {
+		return
+	}
+
+	if err := p.removeUser(program.Name, userID); err != nil {
+		writeError(req.w, http.StatusInternalServerError, "Failed to save the participants.")
+		return
+	}
+
+	req.w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Plugin) apiUnpause(req *apiRequest, program *Program, userID string) {
+	if !p.requireSelfOrAdmin(req, userID) {
+		return
+	}
+
+	if !utils.Contains(program.Users, userID) {
+		writeError(req.w, http.StatusNotFound, fmt.Sprintf("The user %s is not a participant of the program %s.", userID, program.Name))
+		return
+	}
+
+	err := p.updateProgram(program.Name, func(program *Program) error {
+		clearPause(program, userID)
+		return nil
+	})
+
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, "Failed to save the pause.")
+		return
+	}
+
+	program, _ = p.getProgram(program.Name)
+	writeJSON(req.w, http.StatusOK, newParticipantResponse(program, userID))
+}
+
+func (p *Plugin) apiGetHistory(req *apiRequest, program *Program) {
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	writeJSON(req.w, http.StatusOK, newMeetingResponses(program.History))
+}
+
+func (p *Plugin) apiReplaceHistory(req *apiRequest, program *Program) {
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	meetings := []*meetingResponse{}
+	if err := json.NewDecoder(req.r.Body).Decode(&meetings); err != nil {
+		writeError(req.w, http.StatusBadRequest, fmt.Sprintf("Failed parsing json: %s.", err.Error()))
+		return
+	}
+
+	history := History{}
+	for i, meeting := range meetings {
+		if meeting == nil || len(meeting.Participants) < 2 {
+			writeError(req.w, http.StatusBadRequest, fmt.Sprintf("The meeting %d must have at least two participants.", i))
+			return
+		}
+
+		history = append(history, &MeetingRecord{
+			RoundID:        meeting.RoundID,
+			Timestamp:      meeting.Timestamp,
+			Participants:   meeting.Participants,
+			ChannelID:      meeting.ChannelID,
+			PostID:         meeting.PostID,
+			FollowUpAt:     meeting.FollowUpAt,
+			FollowUpPostID: meeting.FollowUpPostID,
+			Feedback:       meeting.Feedback,
+		})
+	}
+
+	// the history goes from the oldest to the newest meeting
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp < history[j].Timestamp
+	})
+
+	err := p.updateProgram(program.Name, func(program *Program) error {
+		program.History = history
+		return nil
+	})
+
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, "Failed to save the meetings.")
+		return
+	}
+
+	writeJSON(req.w, http.StatusOK, newMeetingResponses(history))
+}
+
+func (p *Plugin) apiGetOdd(req *apiRequest, program *Program) {
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	writeJSON(req.w, http.StatusOK, oddResponse{
+		Policy:      p.getConfiguration().getOddUserPolicy(),
+		OddUser:     program.OddUser,
+		OddUserTurn: program.OddUserTurn,
+	})
+}
+
+func (p *Plugin) apiRunRound(req *apiRequest, program *Program) {
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	records, err := p.runManualRound(program.Name, req.userID)
+	if err == errRoundRunning {
+		writeError(req.w, http.StatusConflict, "A round of the program is running.")
+		return
+	}
+
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to run the round of the program %s: %s", program.Name, err.Error()))
+		writeError(req.w, http.StatusInternalServerError, "Failed to run the round.")
+		return
+	}
+
+	writeJSON(req.w, http.StatusOK, newMeetingResponses(records))
+}
+
+func (p *Plugin) apiPreview(req *apiRequest, program *Program) {
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	writeJSON(req.w, http.StatusOK, p.previewRound(program))
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// serveBlocksAPI manages the block rules, users manage their own rules and admins the rules of the admins
+func (p *Plugin) serveBlocksAPI(req *apiRequest) {
+	admin := p.isAdmin(req)
+
+	switch {
+	case req.r.Method == http.MethodGet && len(req.path) == 1:
+		writeJSON(req.w, http.StatusOK, p.state.Get().UserBlocks(req.userID, admin))
+	case req.r.Method == http.MethodPost && len(req.path) == 1:
+		body := struct {
+			UserID string   `json:"user_id"`
+			Users  []string `json:"users"`
+		}{}
+
+		if err := json.NewDecoder(req.r.Body).Decode(&body); err != nil || (body.UserID == "" && len(body.Users) != 2) {
+			writeError(req.w, http.StatusBadRequest, "The body must be {\"user_id\": \"...\"} or {\"users\": [\"...\", \"...\"]}.")
+			return
+		}
+
+		users := []string{req.userID, body.UserID}
+		if body.UserID == "" {
+			if !p.requireAdmin(req) {
+				return
+			}
+
+			users = body.Users
+		}
+
+		rule, err := p.addBlock(users[0], users[1], req.userID, body.UserID == "")
+		if err != nil {
+			writeError(req.w, http.StatusBadRequest, fmt.Sprintf("Failed to save the block rule: %s.", err.Error()))
+			return
+		}
+
+		writeJSON(req.w, http.StatusOK, rule)
+	case req.r.Method == http.MethodDelete && len(req.path) == 2:
+		err := p.removeBlock(req.path[1], req.userID, admin)
+		if err == errBlockNotFound {
+			writeError(req.w, http.StatusNotFound, "The block rule doesn't exist.")
+			return
+		}
+
+		if err != nil {
+			writeError(req.w, http.StatusInternalServerError, "Failed to save the block rules.")
+			return
+		}
+
+		req.w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(req.w, http.StatusNotFound, "Not found.")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	request := func(userID string, method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if userID != "" {
+			r.Header.Set("Mattermost-User-Id", userID)
+		}
+
+		p.ServeHTTP(nil, w, r)
+
+		return w
+	}
+
+	assert.Equal(http.StatusUnauthorized, request("", http.MethodGet, "/api/v1/programs", "").Code)
+	assert.Equal(http.StatusNotFound, request("alice", http.MethodGet, "/api/v1/programs/sales/participants", "").Code)
+	assert.Equal(http.StatusNotFound, request("alice", http.MethodGet, "/api/v1/programs/default/unknown", "").Code)
+
+	w := request("alice", http.MethodGet, "/api/v1/programs", "")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`["default"]`, w.Body.String())
+
+	// users manage their own participation, admins manage everyone's
+	assert.Equal(http.StatusOK, request("alice", http.MethodPost, "/api/v1/programs/default/participants", `{"user_id": "alice"}`).Code)
+	assert.Equal(http.StatusForbidden, request("alice", http.MethodPost, "/api/v1/programs/default/participants", `{"user_id": "bob"}`).Code)
+	assert.Equal(http.StatusOK, request("admin", http.MethodPost, "/api/v1/programs/default/participants", `{"user_id": "bob"}`).Code)
+	assert.Equal(http.StatusBadRequest, request("admin", http.MethodPost, "/api/v1/programs/default/participants", `{}`).Code)
+
+	assert.Equal(http.StatusOK, request("alice", http.MethodPost, "/api/v1/programs/default/participants/alice/pause", "").Code)
+	assert.Equal(http.StatusNotFound, request("carol", http.MethodPost, "/api/v1/programs/default/participants/carol/pause", "").Code)
+
+	assert.Equal(http.StatusOK, request("bob", http.MethodPut, "/api/v1/programs/default/participants/bob/frequency", `{"frequency": "monthly"}`).Code)
+	assert.Equal(http.StatusBadRequest, request("bob", http.MethodPut, "/api/v1/programs/default/participants/bob/frequency", `{"frequency": "daily"}`).Code)
+
+	assert.Equal(http.StatusForbidden, request("alice", http.MethodGet, "/api/v1/programs/default/participants", "").Code)
+	w = request("admin", http.MethodGet, "/api/v1/programs/default/participants", "")
+	assert.JSONEq(`[{"user_id": "alice", "paused": true, "frequency": "always"}, {"user_id": "bob", "paused": false, "frequency": "monthly"}]`, w.Body.String())
+
+	assert.Equal(http.StatusOK, request("alice", http.MethodDelete, "/api/v1/programs/default/participants/alice/pause", "").Code)
+	assert.Empty(p.state.Get().Programs[defaultProgram].Paused)
+
+	// the history is sorted and meetings need two participants
+	assert.Equal(http.StatusBadRequest, request("admin", http.MethodPut, "/api/v1/programs/default/history", `[{"participants": ["alice"]}]`).Code)
+	assert.Equal(http.StatusForbidden, request("alice", http.MethodPut, "/api/v1/programs/default/history", `[]`).Code)
+	w = request("admin", http.MethodPut, "/api/v1/programs/default/history", `[
+		{"round_id": "2", "timestamp": 20, "participants": ["alice", "bob"]},
+		{"round_id": "1", "timestamp": 10, "participants": ["bob", "alice"]}
+	]`)
+	assert.Equal(http.StatusOK, w.Code)
+
+	history := []*meetingResponse{}
+	w = request("admin", http.MethodGet, "/api/v1/programs/default/history", "")
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Len(history, 2)
+	assert.Equal("1", history[0].RoundID)
+	assert.Equal("1", p.state.Get().Programs[defaultProgram].History[0].RoundID)
+
+	preview := RoundPreview{}
+	w = request("admin", http.MethodGet, "/api/v1/programs/default/preview", "")
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Len(preview.Groups, 1)
+	assert.ElementsMatch([]string{"alice", "bob"}, preview.Groups[0])
+	assert.Len(preview.Repeats, 1)
+	assert.Equal("2", preview.Repeats[0].RoundID)
+
+	w = request("admin", http.MethodPost, "/api/v1/programs/default/rounds", "")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Len(p.state.Get().Programs[defaultProgram].History, 3)
+
+	assert.Equal(http.StatusNoContent, request("bob", http.MethodDelete, "/api/v1/programs/default/participants/bob", "").Code)
+	assert.Equal([]string{"alice"}, p.state.Get().Programs[defaultProgram].Users)
+}
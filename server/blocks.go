@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// errBlockNotFound is returned when removing a block rule that doesn't exist or belongs to someone else
+var errBlockNotFound = errors.New("the block rule doesn't exist")
+
+// BlockRule keeps two users from meeting. Users block someone for themselves, admins block any pair,
+// e.g. a manager and their direct report. Only admins can remove the rules of the admins.
+type BlockRule struct {
+	ID        string   `json:"id"`
+	Users     []string `json:"users"`
+	CreatedBy string   `json:"created_by"`
+	CreatedAt int64    `json:"created_at"`
+	Admin     bool     `json:"admin"`
+}
+
+// newBlockRule returns the rule that keeps userID and pairUserID from meeting
+func newBlockRule(userID string, pairUserID string, createdBy string, admin bool) *BlockRule {
+	return &BlockRule{
+		ID:        model.NewId(),
+		Users:     []string{userID, pairUserID},
+		CreatedBy: createdBy,
+		CreatedAt: model.GetMillis(),
+		Admin:     admin,
+	}
+}
+
+// Blocked returns, for every user, the users they must not meet
+func (s *State) Blocked() map[string][]string {
+	blocked := make(map[string][]string)
+
+	for _, rule := range s.Blocks {
+		userID, pairUserID := rule.Users[0], rule.Users[1]
+		blocked[userID] = append(blocked[userID], pairUserID)
+		blocked[pairUserID] = append(blocked[pairUserID], userID)
+	}
+
+	return blocked
+}
+
+// FindBlock returns the rule of the pair created by the user, or by any admin if admin is true
+func (s *State) FindBlock(userID string, pairUserID string, createdBy string, admin bool) (*BlockRule, bool) {
+	for _, rule := range s.Blocks {
+		if !utils.Contains(rule.Users, userID) || !utils.Contains(rule.Users, pairUserID) || rule.Admin != admin {
+			continue
+		}
+
+		if admin || rule.CreatedBy == createdBy {
+			return rule, true
+		}
+	}
+
+	return nil, false
+}
+
+// UserBlocks returns the rules created by the user, or all of them if all is true
+func (s *State) UserBlocks(userID string, all bool) []*BlockRule {
+	rules := []*BlockRule{}
+
+	for _, rule := range s.Blocks {
+		if all || (!rule.Admin && rule.CreatedBy == userID) {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// addBlock saves the rule unless the same rule exists, it returns the saved rule
+func (p *Plugin) addBlock(userID string, pairUserID string, createdBy string, admin bool) (*BlockRule, error) {
+	if userID == pairUserID {
+		return nil, errors.New("users can't block themselves")
+	}
+
+	var saved *BlockRule
+
+	err := p.updateState(func(state *State) error {
+		rule, ok := state.FindBlock(userID, pairUserID, createdBy, admin)
+		if !ok {
+			rule = newBlockRule(userID, pairUserID, createdBy, admin)
+			state.Blocks = append(state.Blocks, rule)
+		}
+
+		saved = rule
+		return nil
+	})
+
+	return saved, err
+}
+
+// removeBlock removes the rule, users can only remove their own rules and admins any rule
+func (p *Plugin) removeBlock(id string, userID string, admin bool) error {
+	return p.updateState(func(state *State) error {
+		for i, rule := range state.Blocks {
+			if rule.ID == id && (admin || (!rule.Admin && rule.CreatedBy == userID)) {
+				state.Blocks = append(state.Blocks[:i], state.Blocks[i+1:]...)
+				return nil
+			}
+		}
+
+		return errBlockNotFound
+	})
+}
+
+// executeBlockCommand runs the block commands and returns the message for the user
+func (p *Plugin) executeBlockCommand(command string, args *model.CommandArgs, admin bool) string {
+	mentions := []string{}
+	for _, userID := range args.UserMentions {
+		mentions = append(mentions, userID)
+	}
+	sort.Strings(mentions)
+
+	switch command {
+	case "block":
+		if len(mentions) == 0 {
+			return "Mention the users you don't want to meet, e.g. `/gather-plugin block @alice`."
+		}
+
+		for _, userID := range mentions {
+			if _, err := p.addBlock(args.UserId, userID, args.UserId, false); err != nil {
+				return fmt.Sprintf("Failed to block %s: %s.", p.usernamesText([]string{userID}), err.Error())
+			}
+		}
+
+		return fmt.Sprintf("You won't meet %s.", p.usernamesText(mentions))
+	case "unblock":
+		if len(mentions) == 0 {
+			return "Mention the users you want to meet again, e.g. `/gather-plugin unblock @alice`."
+		}
+
+		for _, userID := range mentions {
+			if rule, ok := p.state.Get().FindBlock(args.UserId, userID, args.UserId, false); ok {
+				if err := p.removeBlock(rule.ID, args.UserId, false); err != nil {
+					return "Failed to save the block rules, contact your administrator."
+				}
+			}
+		}
+
+		return fmt.Sprintf("You can meet %s again.", p.usernamesText(mentions))
+	case "blocks":
+		return p.blocksText(p.state.Get().UserBlocks(args.UserId, false), "You haven't blocked anyone.")
+	case "never_pair", "allow_pair":
+		if len(mentions) != 2 {
+			return fmt.Sprintf("Mention two users, e.g. `/gather-plugin %s @alice @bob`.", command)
+		}
+
+		if command == "never_pair" {
+			if _, err := p.addBlock(mentions[0], mentions[1], args.UserId, true); err != nil {
+				return fmt.Sprintf("Failed to block the pair: %s.", err.Error())
+			}
+
+			return fmt.Sprintf("%s won't meet.", p.usernamesText(mentions))
+		}
+
+		if rule, ok := p.state.Get().FindBlock(mentions[0], mentions[1], args.UserId, true); ok {
+			if err := p.removeBlock(rule.ID, args.UserId, true); err != nil {
+				return "Failed to save the block rules."
+			}
+		}
+
+		return fmt.Sprintf("The admins don't keep %s from meeting anymore, their own blocks still apply.", p.usernamesText(mentions))
+	case "pair_rules":
+		return p.blocksText(p.state.Get().UserBlocks(args.UserId, admin), "There are no block rules.")
+	}
+
+	return "This command is not supported"
+}
+
+// blocksText lists the rules for the user
+func (p *Plugin) blocksText(rules []*BlockRule, empty string) string {
+	if len(rules) == 0 {
+		return empty
+	}
+
+	var msgBuilder strings.Builder
+	msgBuilder.WriteString("Pairs that won't meet:\n")
+
+	for _, rule := range rules {
+		by := "blocked by " + p.usernamesText([]string{rule.CreatedBy})
+		if rule.Admin {
+			by = "blocked by the admins"
+		}
+
+		msgBuilder.WriteString(fmt.Sprintf(" - %s, %s\n", p.usernamesText(rule.Users), by))
+	}
+
+	return msgBuilder.String()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockCommands(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	run := func(userID string, command string, mentions ...string) string {
+		args := &model.CommandArgs{UserId: userID, Command: command}
+		for _, mention := range mentions {
+			args.AddUserMention(mention, mention)
+		}
+
+		response, err := p.ExecuteCommand(nil, args)
+		assert.Nil(err)
+		return response.Text
+	}
+
+	assert.Equal("You won't meet @bob.", run("alice", "/gather-plugin block @bob", "bob"))
+	run("alice", "/gather-plugin block @bob", "bob")
+	assert.Len(p.state.Get().Blocks, 1)
+	assert.Contains(run("alice", "/gather-plugin blocks"), "@alice and @bob, blocked by @alice")
+	assert.Equal("You haven't blocked anyone.", run("bob", "/gather-plugin blocks"))
+
+	assert.Equal("Only system admins can do this.", run("alice", "/gather-plugin never_pair @bob @carol", "bob", "carol"))
+	assert.Equal("@bob and @carol won't meet.", run("admin", "/gather-plugin never_pair @bob @carol", "bob", "carol"))
+	assert.Contains(run("admin", "/gather-plugin never_pair @bob", "bob"), "Mention two users")
+
+	// users can't remove the rules of the admins
+	run("bob", "/gather-plugin unblock @carol", "carol")
+	assert.Len(p.state.Get().Blocks, 2)
+
+	blocked := p.state.Get().Blocked()
+	assert.ElementsMatch([]string{"alice", "carol"}, blocked["bob"])
+
+	run("alice", "/gather-plugin unblock @bob", "bob")
+	run("admin", "/gather-plugin allow_pair @bob @carol", "bob", "carol")
+	assert.Empty(p.state.Get().Blocks)
+}
+
+func TestBlocksAPI(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	request := func(userID string, method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Mattermost-User-Id", userID)
+		p.ServeHTTP(nil, w, r)
+		return w
+	}
+
+	rule := BlockRule{}
+	w := request("alice", http.MethodPost, "/api/v1/blocks", `{"user_id": "bob"}`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Nil(json.Unmarshal(w.Body.Bytes(), &rule))
+	assert.Equal([]string{"alice", "bob"}, rule.Users)
+	assert.False(rule.Admin)
+
+	assert.Equal(http.StatusBadRequest, request("alice", http.MethodPost, "/api/v1/blocks", `{"user_id": "alice"}`).Code)
+	assert.Equal(http.StatusForbidden, request("alice", http.MethodPost, "/api/v1/blocks", `{"users": ["bob", "carol"]}`).Code)
+	assert.Equal(http.StatusOK, request("admin", http.MethodPost, "/api/v1/blocks", `{"users": ["bob", "carol"]}`).Code)
+
+	rules := []*BlockRule{}
+	assert.Nil(json.Unmarshal(request("alice", http.MethodGet, "/api/v1/blocks", "").Body.Bytes(), &rules))
+	assert.Len(rules, 1)
+	assert.Nil(json.Unmarshal(request("admin", http.MethodGet, "/api/v1/blocks", "").Body.Bytes(), &rules))
+	assert.Len(rules, 2)
+
+	assert.Equal(http.StatusNotFound, request("bob", http.MethodDelete, "/api/v1/blocks/"+rule.ID, "").Code)
+	assert.Equal(http.StatusNoContent, request("alice", http.MethodDelete, "/api/v1/blocks/"+rule.ID, "").Code)
+	assert.Len(p.state.Get().Blocks, 1)
+}
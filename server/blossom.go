@@ -0,0 +1,703 @@
+package main
+
+// weightedEdge is an undirected edge between the vertices I and J
+type weightedEdge struct {
+	I      int
+	J      int
+	Weight int64
+}
+
+// maxWeightMatching computes a maximum-weight matching of a general graph using Edmonds' blossom
+// algorithm in O(n^3). If maxCardinality is true only maximum-cardinality matchings are considered.
+// It returns, for each vertex, the vertex it's matched with or -1.
+//
+// Port of the well known implementation by Joris van Rantwijk, based on "Efficient Algorithms for
+// Finding Maximum Matching in Graphs" by Zvi Galil.
+func maxWeightMatching(edges []weightedEdge, maxCardinality bool) []int {
+	if len(edges) == 0 {
+		return []int{}
+	}
+
+	b := newBlossomMatching(edges, maxCardinality)
+	b.solve()
+
+	return b.result()
+}
+
+type blossomMatching struct {
+	edges          []weightedEdge
+	maxCardinality bool
+	nvertex        int
+
+	// endpoint[p] is the vertex of the endpoint p, edge k has endpoints 2k and 2k+1
+	endpoint []int
+	// neighbend[v] is the list of remote endpoints of the edges attached to v
+	neighbend [][]int
+	// mate[v] is the remote endpoint of the matched edge of v or -1
+	mate []int
+
+	label            []int
+	labelend         []int
+	inblossom        []int
+	blossomparent    []int
+	blossomchilds    [][]int
+	blossombase      []int
+	blossomendps     [][]int
+	bestedge         []int
+	blossombestedges [][]int
+	unusedblossoms   []int
+	dualvar          []int64
+	allowedge        []bool
+	queue            []int
+}
+
+func newBlossomMatching(edges []weightedEdge, maxCardinality bool) *blossomMatching {
+	nedge := len(edges)
+	nvertex := 0
+	maxweight := int64(0)
+	scaled := make([]weightedEdge, nedge)
+
+	for k, e := range edges {
+		if e.I >= nvertex {
+			nvertex = e.I + 1
+		}
+		if e.J >= nvertex {
+			nvertex = e.J + 1
+		}
+
+		// doubling the weights keeps every dual variable integral
+		scaled[k] = weightedEdge{I: e.I, J: e.J, Weight: 2 * e.Weight}
+
+		if scaled[k].Weight > maxweight {
+			maxweight = scaled[k].Weight
+		}
+	}
+
+	b := &blossomMatching{
+		edges:            scaled,
+		maxCardinality:   maxCardinality,
+		nvertex:          nvertex,
+		endpoint:         make([]int, 2*nedge),
+		neighbend:        make([][]int, nvertex),
+		mate:             make([]int, nvertex),
+		label:            make([]int, 2*nvertex),
+		labelend:         make([]int, 2*nvertex),
+		inblossom:        make([]int, nvertex),
+		blossomparent:    make([]int, 2*nvertex),
+		blossomchilds:    make([][]int, 2*nvertex),
+		blossombase:      make([]int, 2*nvertex),
+		blossomendps:     make([][]int, 2*nvertex),
+		bestedge:         make([]int, 2*nvertex),
+		blossombestedges: make([][]int, 2*nvertex),
+		unusedblossoms:   []int{},
+		dualvar:          make([]int64, 2*nvertex),
+		allowedge:        make([]bool, nedge),
+	}
+
+	for p := range b.endpoint {
+		if p%2 == 0 {
+			b.endpoint[p] = scaled[p/2].I
+		} else {
+			b.endpoint[p] = scaled[p/2].J
+		}
+	}
+
+	for k, e := range scaled {
+		b.neighbend[e.I] = append(b.neighbend[e.I], 2*k+1)
+		b.neighbend[e.J] = append(b.neighbend[e.J], 2*k)
+	}
+
+	for v := 0; v < nvertex; v++ {
+		b.mate[v] = -1
+		b.inblossom[v] = v
+		b.blossombase[v] = v
+		b.blossombase[nvertex+v] = -1
+		b.dualvar[v] = maxweight
+		b.unusedblossoms = append(b.unusedblossoms, nvertex+v)
+	}
+
+	for i := range b.labelend {
+		b.labelend[i] = -1
+		b.blossomparent[i] = -1
+		b.bestedge[i] = -1
+	}
+
+	return b
+}
+
+func (b *blossomMatching) result() []int {
+	mate := make([]int, b.nvertex)
+
+	for v := 0; v < b.nvertex; v++ {
+		mate[v] = -1
+		if b.mate[v] >= 0 {
+			mate[v] = b.endpoint[b.mate[v]]
+		}
+	}
+
+	return mate
+}
+
+func (b *blossomMatching) slack(k int) int64 {
+	e := b.edges[k]
+	return b.dualvar[e.I] + b.dualvar[e.J] - 2*e.Weight
+}
+
+func (b *blossomMatching) blossomLeaves(t int) []int {
+	if t < b.nvertex {
+		return []int{t}
+	}
+
+	leaves := []int{}
+	for _, c := range b.blossomchilds[t] {
+		leaves = append(leaves, b.blossomLeaves(c)...)
+	}
+
+	return leaves
+}
+
+// at indexes a blossom list allowing negative positions, as the cycle is walked in both directions
+func at(list []int, i int) int {
+	n := len(list)
+	return list[((i%n)+n)%n]
+}
+
+func indexOf(list []int, e int) int {
+	for i, v := range list {
+		if v == e {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// assignLabel labels the top-level blossom containing w with t through the edge endpoint p
+func (b *blossomMatching) assignLabel(w int, t int, p int) {
+	bw := b.inblossom[w]
+	b.label[w] = t
+	b.label[bw] = t
+	b.labelend[w] = p
+	b.labelend[bw] = p
+	b.bestedge[w] = -1
+	b.bestedge[bw] = -1
+
+	if t == 1 {
+		b.queue = append(b.queue, b.blossomLeaves(bw)...)
+	} else if t == 2 {
+		base := b.blossombase[bw]
+		b.assignLabel(b.endpoint[b.mate[base]], 1, b.mate[base]^1)
+	}
+}
+
+// scanBlossom traces back from v and w to find a new blossom or an augmenting path,
+// it returns the base of the blossom or -1
+func (b *blossomMatching) scanBlossom(v int, w int) int {
+	path := []int{}
+	base := -1
+
+	for v != -1 || w != -1 {
+		bv := b.inblossom[v]
+
+		if b.label[bv]&4 != 0 {
+			base = b.blossombase[bv]
+			break
+		}
+
+		path = append(path, bv)
+		b.label[bv] = 5
+
+		if b.labelend[bv] == -1 {
+			v = -1
+		} else {
+			v = b.endpoint[b.labelend[bv]]
+			bv = b.inblossom[v]
+			v = b.endpoint[b.labelend[bv]]
+		}
+
+		if w != -1 {
+			v, w = w, v
+		}
+	}
+
+	for _, bv := range path {
+		b.label[bv] = 1
+	}
+
+	return base
+}
+
+// addBlossom builds a new blossom with the given base through the edge k
+func (b *blossomMatching) addBlossom(base int, k int) {
+	e := b.edges[k]
+	v, w := e.I, e.J
+	bb := b.inblossom[base]
+	bv := b.inblossom[v]
+	bw := b.inblossom[w]
+
+	nb := b.unusedblossoms[len(b.unusedblossoms)-1]
+	b.unusedblossoms = b.unusedblossoms[:len(b.unusedblossoms)-1]
+
+	b.blossombase[nb] = base
+	b.blossomparent[nb] = -1
+	b.blossomparent[bb] = nb
+
+	path := []int{}
+	endps := []int{}
+
+	for bv != bb {
+		b.blossomparent[bv] = nb
+		path = append(path, bv)
+		endps = append(endps, b.labelend[bv])
+		v = b.endpoint[b.labelend[bv]]
+		bv = b.inblossom[v]
+	}
+
+	path = append(path, bb)
+	reverseInts(path)
+	reverseInts(endps)
+	endps = append(endps, 2*k)
+
+	for bw != bb {
+		b.blossomparent[bw] = nb
+		path = append(path, bw)
+		endps = append(endps, b.labelend[bw]^1)
+		w = b.endpoint[b.labelend[bw]]
+		bw = b.inblossom[w]
+	}
+
+	b.blossomchilds[nb] = path
+	b.blossomendps[nb] = endps
+	b.label[nb] = 1
+	b.labelend[nb] = b.labelend[bb]
+	b.dualvar[nb] = 0
+
+	for _, leaf := range b.blossomLeaves(nb) {
+		if b.label[b.inblossom[leaf]] == 2 {
+			b.queue = append(b.queue, leaf)
+		}
+		b.inblossom[leaf] = nb
+	}
+
+	bestedgeto := make([]int, 2*b.nvertex)
+	for i := range bestedgeto {
+		bestedgeto[i] = -1
+	}
+
+	for _, child := range path {
+		var nblists [][]int
+
+		if b.blossombestedges[child] == nil {
+			for _, leaf := range b.blossomLeaves(child) {
+				nblist := []int{}
+				for _, p := range b.neighbend[leaf] {
+					nblist = append(nblist, p/2)
+				}
+				nblists = append(nblists, nblist)
+			}
+		} else {
+			nblists = [][]int{b.blossombestedges[child]}
+		}
+
+		for _, nblist := range nblists {
+			for _, nk := range nblist {
+				j := b.edges[nk].J
+				if b.inblossom[j] == nb {
+					j = b.edges[nk].I
+				}
+				bj := b.inblossom[j]
+
+				if bj != nb && b.label[bj] == 1 &&
+					(bestedgeto[bj] == -1 || b.slack(nk) < b.slack(bestedgeto[bj])) {
+					bestedgeto[bj] = nk
+				}
+			}
+		}
+
+		b.blossombestedges[child] = nil
+		b.bestedge[child] = -1
+	}
+
+	best := []int{}
+	for _, nk := range bestedgeto {
+		if nk != -1 {
+			best = append(best, nk)
+		}
+	}
+
+	b.blossombestedges[nb] = best
+	b.bestedge[nb] = -1
+
+	for _, nk := range best {
+		if b.bestedge[nb] == -1 || b.slack(nk) < b.slack(b.bestedge[nb]) {
+			b.bestedge[nb] = nk
+		}
+	}
+}
+
+// expandBlossom undoes the blossom t, relabeling its children when it happens in the middle of a stage
+func (b *blossomMatching) expandBlossom(t int, endstage bool) {
+	for _, s := range b.blossomchilds[t] {
+		b.blossomparent[s] = -1
+
+		if s < b.nvertex {
+			b.inblossom[s] = s
+		} else if endstage && b.dualvar[s] == 0 {
+			b.expandBlossom(s, endstage)
+		} else {
+			for _, leaf := range b.blossomLeaves(s) {
+				b.inblossom[leaf] = s
+			}
+		}
+	}
+
+	if !endstage && b.label[t] == 2 {
+		childs := b.blossomchilds[t]
+		endps := b.blossomendps[t]
+		entrychild := b.inblossom[b.endpoint[b.labelend[t]^1]]
+		j := indexOf(childs, entrychild)
+
+		var jstep, endptrick int
+		if j&1 != 0 {
+			j -= len(childs)
+			jstep = 1
+			endptrick = 0
+		} else {
+			jstep = -1
+			endptrick = 1
+		}
+
+		p := b.labelend[t]
+
+		for j != 0 {
+			b.label[b.endpoint[p^1]] = 0
+			b.label[b.endpoint[at(endps, j-endptrick)^endptrick^1]] = 0
+			b.assignLabel(b.endpoint[p^1], 2, p)
+			b.allowedge[at(endps, j-endptrick)/2] = true
+			j += jstep
+			p = at(endps, j-endptrick) ^ endptrick
+			b.allowedge[p/2] = true
+			j += jstep
+		}
+
+		bv := at(childs, j)
+		b.label[b.endpoint[p^1]] = 2
+		b.label[bv] = 2
+		b.labelend[b.endpoint[p^1]] = p
+		b.labelend[bv] = p
+		b.bestedge[bv] = -1
+		j += jstep
+
+		for at(childs, j) != entrychild {
+			bv = at(childs, j)
+
+			if b.label[bv] == 1 {
+				j += jstep
+				continue
+			}
+
+			v := -1
+			for _, leaf := range b.blossomLeaves(bv) {
+				v = leaf
+				if b.label[leaf] != 0 {
+					break
+				}
+			}
+
+			if v != -1 && b.label[v] != 0 {
+				b.label[v] = 0
+				b.label[b.endpoint[b.mate[b.blossombase[bv]]]] = 0
+				b.assignLabel(v, 2, b.labelend[v])
+			}
+
+			j += jstep
+		}
+	}
+
+	b.label[t] = -1
+	b.labelend[t] = -1
+	b.blossomchilds[t] = nil
+	b.blossomendps[t] = nil
+	b.blossombase[t] = -1
+	b.blossombestedges[t] = nil
+	b.bestedge[t] = -1
+	b.unusedblossoms = append(b.unusedblossoms, t)
+}
+
+// augmentBlossom swaps matched and unmatched edges inside the blossom t so v becomes its base
+func (b *blossomMatching) augmentBlossom(t int, v int) {
+	s := v
+	for b.blossomparent[s] != t {
+		s = b.blossomparent[s]
+	}
+
+	if s >= b.nvertex {
+		b.augmentBlossom(s, v)
+	}
+
+	childs := b.blossomchilds[t]
+	endps := b.blossomendps[t]
+	i := indexOf(childs, s)
+	j := i
+
+	var jstep, endptrick int
+	if i&1 != 0 {
+		j -= len(childs)
+		jstep = 1
+		endptrick = 0
+	} else {
+		jstep = -1
+		endptrick = 1
+	}
+
+	for j != 0 {
+		j += jstep
+		s = at(childs, j)
+		p := at(endps, j-endptrick) ^ endptrick
+
+		if s >= b.nvertex {
+			b.augmentBlossom(s, b.endpoint[p])
+		}
+
+		j += jstep
+		s = at(childs, j)
+
+		if s >= b.nvertex {
+			b.augmentBlossom(s, b.endpoint[p^1])
+		}
+
+		b.mate[b.endpoint[p]] = p ^ 1
+		b.mate[b.endpoint[p^1]] = p
+	}
+
+	b.blossomchilds[t] = append(append([]int{}, childs[i:]...), childs[:i]...)
+	b.blossomendps[t] = append(append([]int{}, endps[i:]...), endps[:i]...)
+	b.blossombase[t] = b.blossombase[b.blossomchilds[t][0]]
+}
+
+// augmentMatching augments the matching along the path through the edge k
+func (b *blossomMatching) augmentMatching(k int) {
+	e := b.edges[k]
+
+	for _, start := range [][2]int{{e.I, 2*k + 1}, {e.J, 2 * k}} {
+		s, p := start[0], start[1]
+
+		for {
+			bs := b.inblossom[s]
+
+			if bs >= b.nvertex {
+				b.augmentBlossom(bs, s)
+			}
+
+			b.mate[s] = p
+
+			if b.labelend[bs] == -1 {
+				break
+			}
+
+			t := b.endpoint[b.labelend[bs]]
+			bt := b.inblossom[t]
+			s = b.endpoint[b.labelend[bt]]
+			j := b.endpoint[b.labelend[bt]^1]
+
+			if bt >= b.nvertex {
+				b.augmentBlossom(bt, j)
+			}
+
+			b.mate[j] = b.labelend[bt]
+			p = b.labelend[bt] ^ 1
+		}
+	}
+}
+
+func (b *blossomMatching) solve() {
+	nvertex := b.nvertex
+
+	for stage := 0; stage < nvertex; stage++ {
+		for i := range b.label {
+			b.label[i] = 0
+			b.bestedge[i] = -1
+		}
+		for i := nvertex; i < 2*nvertex; i++ {
+			b.blossombestedges[i] = nil
+		}
+		for i := range b.allowedge {
+			b.allowedge[i] = false
+		}
+		b.queue = []int{}
+
+		for v := 0; v < nvertex; v++ {
+			if b.mate[v] == -1 && b.label[b.inblossom[v]] == 0 {
+				b.assignLabel(v, 1, -1)
+			}
+		}
+
+		augmented := false
+
+		for {
+			for len(b.queue) > 0 && !augmented {
+				v := b.queue[len(b.queue)-1]
+				b.queue = b.queue[:len(b.queue)-1]
+
+				for _, p := range b.neighbend[v] {
+					k := p / 2
+					w := b.endpoint[p]
+
+					if b.inblossom[v] == b.inblossom[w] {
+						continue
+					}
+
+					var kslack int64
+					if !b.allowedge[k] {
+						kslack = b.slack(k)
+						if kslack <= 0 {
+							b.allowedge[k] = true
+						}
+					}
+
+					if b.allowedge[k] {
+						if b.label[b.inblossom[w]] == 0 {
+							b.assignLabel(w, 2, p^1)
+						} else if b.label[b.inblossom[w]] == 1 {
+							base := b.scanBlossom(v, w)
+							if base >= 0 {
+								b.addBlossom(base, k)
+							} else {
+								b.augmentMatching(k)
+								augmented = true
+								break
+							}
+						} else if b.label[w] == 0 {
+							b.label[w] = 2
+							b.labelend[w] = p ^ 1
+						}
+					} else if b.label[b.inblossom[w]] == 1 {
+						bv := b.inblossom[v]
+						if b.bestedge[bv] == -1 || kslack < b.slack(b.bestedge[bv]) {
+							b.bestedge[bv] = k
+						}
+					} else if b.label[w] == 0 {
+						if b.bestedge[w] == -1 || kslack < b.slack(b.bestedge[w]) {
+							b.bestedge[w] = k
+						}
+					}
+				}
+			}
+
+			if augmented {
+				break
+			}
+
+			deltatype := -1
+			var delta int64
+			deltaedge := -1
+			deltablossom := -1
+
+			if !b.maxCardinality {
+				deltatype = 1
+				delta = minInt64(b.dualvar[:nvertex])
+			}
+
+			for v := 0; v < nvertex; v++ {
+				if b.label[b.inblossom[v]] == 0 && b.bestedge[v] != -1 {
+					d := b.slack(b.bestedge[v])
+					if deltatype == -1 || d < delta {
+						delta = d
+						deltatype = 2
+						deltaedge = b.bestedge[v]
+					}
+				}
+			}
+
+			for t := 0; t < 2*nvertex; t++ {
+				if b.blossomparent[t] == -1 && b.label[t] == 1 && b.bestedge[t] != -1 {
+					d := b.slack(b.bestedge[t]) / 2
+					if deltatype == -1 || d < delta {
+						delta = d
+						deltatype = 3
+						deltaedge = b.bestedge[t]
+					}
+				}
+			}
+
+			for t := nvertex; t < 2*nvertex; t++ {
+				if b.blossombase[t] >= 0 && b.blossomparent[t] == -1 && b.label[t] == 2 &&
+					(deltatype == -1 || b.dualvar[t] < delta) {
+					delta = b.dualvar[t]
+					deltatype = 4
+					deltablossom = t
+				}
+			}
+
+			if deltatype == -1 {
+				// no further improvement possible, max-cardinality optimum reached
+				deltatype = 1
+				delta = minInt64(b.dualvar[:nvertex])
+				if delta < 0 {
+					delta = 0
+				}
+			}
+
+			for v := 0; v < nvertex; v++ {
+				if b.label[b.inblossom[v]] == 1 {
+					b.dualvar[v] -= delta
+				} else if b.label[b.inblossom[v]] == 2 {
+					b.dualvar[v] += delta
+				}
+			}
+
+			for t := nvertex; t < 2*nvertex; t++ {
+				if b.blossombase[t] >= 0 && b.blossomparent[t] == -1 {
+					if b.label[t] == 1 {
+						b.dualvar[t] += delta
+					} else if b.label[t] == 2 {
+						b.dualvar[t] -= delta
+					}
+				}
+			}
+
+			if deltatype == 1 {
+				break
+			} else if deltatype == 2 {
+				b.allowedge[deltaedge] = true
+				i := b.edges[deltaedge].I
+				if b.label[b.inblossom[i]] == 0 {
+					i = b.edges[deltaedge].J
+				}
+				b.queue = append(b.queue, i)
+			} else if deltatype == 3 {
+				b.allowedge[deltaedge] = true
+				b.queue = append(b.queue, b.edges[deltaedge].I)
+			} else if deltatype == 4 {
+				b.expandBlossom(deltablossom, false)
+			}
+		}
+
+		if !augmented {
+			break
+		}
+
+		for t := nvertex; t < 2*nvertex; t++ {
+			if b.blossomparent[t] == -1 && b.blossombase[t] >= 0 && b.label[t] == 1 && b.dualvar[t] == 0 {
+				b.expandBlossom(t, true)
+			}
+		}
+	}
+}
+
+func minInt64(values []int64) int64 {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+
+	return min
+}
+
+func reverseInts(list []int) {
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+}
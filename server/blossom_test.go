@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type matchingScore struct {
+	size   int
+	weight int64
+}
+
+func (s matchingScore) better(other matchingScore, maxCardinality bool) bool {
+	if maxCardinality && s.size != other.size {
+		return s.size > other.size
+	}
+
+	return s.weight > other.weight
+}
+
+// bruteForceMatching explores every matching of the graph and returns the best score
+func bruteForceMatching(n int, weights map[[2]int]int64, maxCardinality bool) matchingScore {
+	used := make([]bool, n)
+	best := matchingScore{}
+
+	var search func(v int, current matchingScore)
+	search = func(v int, current matchingScore) {
+		for v < n && used[v] {
+			v++
+		}
+
+		if v == n {
+			if current.better(best, maxCardinality) {
+				best = current
+			}
+			return
+		}
+
+		used[v] = true
+		search(v+1, current)
+
+		for w := v + 1; w < n; w++ {
+			weight, ok := weights[[2]int{v, w}]
+			if ok && !used[w] {
+				used[w] = true
+				search(v+1, matchingScore{current.size + 1, current.weight + weight})
+				used[w] = false
+			}
+		}
+
+		used[v] = false
+	}
+
+	search(0, matchingScore{})
+
+	return best
+}
+
+func TestMaxWeightMatchingKnownGraphs(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]int{}, maxWeightMatching([]weightedEdge{}, false))
+	assert.Equal([]int{1, 0}, maxWeightMatching([]weightedEdge{{0, 1, 1}}, false))
+	assert.Equal([]int{-1, -1, 3, 2}, maxWeightMatching([]weightedEdge{{1, 2, 10}, {2, 3, 11}}, false))
+	assert.Equal([]int{-1, -1, 3, 2, -1}, maxWeightMatching([]weightedEdge{{1, 2, 5}, {2, 3, 11}, {3, 4, 5}}, false))
+	assert.Equal([]int{-1, 2, 1, 4, 3}, maxWeightMatching([]weightedEdge{{1, 2, 5}, {2, 3, 11}, {3, 4, 5}}, true))
+
+	// blossom
+	assert.Equal([]int{-1, 2, 1, 4, 3}, maxWeightMatching([]weightedEdge{{1, 2, 8}, {1, 3, 9}, {2, 3, 10}, {3, 4, 7}}, false))
+	assert.Equal([]int{-1, 6, 3, 2, 5, 4, 1}, maxWeightMatching([]weightedEdge{{1, 2, 8}, {1, 3, 9}, {2, 3, 10}, {3, 4, 7}, {1, 6, 5}, {4, 5, 6}}, false))
+}
+
+func TestMaxWeightMatchingRandomGraphs(t *testing.T) {
+	assert := assert.New(t)
+	random := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		n := 2 + random.Intn(9)
+		maxCardinality := random.Intn(2) == 0
+		weights := make(map[[2]int]int64)
+		edges := []weightedEdge{}
+
+		for v := 0; v < n; v++ {
+			for w := v + 1; w < n; w++ {
+				if random.Intn(3) > 0 {
+					weight := int64(1 + random.Intn(20))
+					weights[[2]int{v, w}] = weight
+					edges = append(edges, weightedEdge{v, w, weight})
+				}
+			}
+		}
+
+		mate := maxWeightMatching(edges, maxCardinality)
+		result := matchingScore{}
+
+		for v, w := range mate {
+			if w != -1 {
+				assert.Equal(v, mate[w])
+			}
+
+			if w > v {
+				result.size++
+				result.weight += weights[[2]int{v, w}]
+			}
+		}
+
+		assert.Equal(bruteForceMatching(n, weights, maxCardinality), result, "graph %v", edges)
+	}
+}
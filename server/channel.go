@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+// channelMembersPerPage is the page size used to load the members of the channels bound to programs
+const channelMembersPerPage = 200
+
+// UserHasJoinedChannel one user joined a channel, they join the programs bound to it
+func (p *Plugin) UserHasJoinedChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	names := p.channelPrograms(channelMember.ChannelId)
+	if len(names) == 0 {
+		return
+	}
+
+	user, err := p.API.GetUser(channelMember.UserId)
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to get the user that joined the channel: %s", err.Error()))
+		return
+	}
+
+	if user.IsBot {
+		return
+	}
+
+	for _, name := range names {
+		p.addUser(name, user.Id)
+	}
+}
+
+// UserHasLeftChannel one user left a channel, they leave the programs bound to it
+func (p *Plugin) UserHasLeftChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	for _, name := range p.channelPrograms(channelMember.ChannelId) {
+		p.removeUser(name, channelMember.UserId)
+	}
+}
+
+// channelPrograms returns the programs bound to the channel
+func (p *Plugin) channelPrograms(channelID string) []string {
+	names := []string{}
+	state := p.state.Get()
+
+	for _, name := range state.ProgramNames() {
+		if state.Programs[name].ChannelID == channelID {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// getChannelMembers returns the users of the channel that can meet, bots and deactivated users are left out
+func (p *Plugin) getChannelMembers(channelID string) ([]string, error) {
+	members := []string{}
+
+	for page := 0; ; page++ {
+		users, err := p.API.GetUsersInChannel(channelID, model.CHANNEL_SORT_BY_USERNAME, page, channelMembersPerPage)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range users {
+			if !user.IsBot && user.DeleteAt == 0 {
+				members = append(members, user.Id)
+			}
+		}
+
+		if len(users) < channelMembersPerPage {
+			return members, nil
+		}
+	}
+}
+
+// syncProgramChannel makes the users of the program the members of its channel
+func (p *Plugin) syncProgramChannel(name string) error {
+	program, ok := p.getProgram(name)
+	if !ok || program.ChannelID == "" {
+		return nil
+	}
+
+	members, err := p.getChannelMembers(program.ChannelID)
+	if err != nil {
+		return err
+	}
+
+	return p.updateProgram(name, func(program *Program) error {
+		syncProgramUsers(program, members)
+		return nil
+	})
+}
+
+// syncChannelPrograms syncs the programs bound to channels, the hooks don't run while the plugin is disabled
+func (p *Plugin) syncChannelPrograms() {
+	for _, name := range p.state.Get().ProgramNames() {
+		if err := p.syncProgramChannel(name); err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to sync the program %s with its channel: %s", name, err.Error()))
+		}
+	}
+}
+
+// syncProgramUsers adds the members that are not users of the program and removes the users that are not members
+func syncProgramUsers(program *Program, members []string) {
+	for _, userID := range append([]string{}, program.Users...) {
+		if !utils.Contains(members, userID) {
+			removeProgramUser(program, userID)
+		}
+	}
+
+	for _, userID := range members {
+		if !utils.Contains(program.Users, userID) {
+			program.Users = append(program.Users, userID)
+		}
+	}
+}
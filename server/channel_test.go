@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncProgramUsers(t *testing.T) {
+	assert := assert.New(t)
+	program := NewProgram("sales")
+	program.Users = []string{"a", "b", "c"}
+	program.OddUserTurn = []string{"b"}
+	program.History = History{{RoundID: "1", Participants: []string{"a", "b"}}}
+
+	syncProgramUsers(program, []string{"a", "c", "d"})
+
+	assert.Equal([]string{"a", "c", "d"}, program.Users)
+	assert.Empty(program.OddUserTurn)
+	assert.Empty(program.History)
+}
+
+func TestChannelMembershipHooks(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	api := p.API.(*plugintest.API)
+	api.On("GetUsersInChannel", "channel1", model.CHANNEL_SORT_BY_USERNAME, 0, channelMembersPerPage).Return([]*model.User{
+		{Id: "alice"},
+		{Id: "bot", IsBot: true},
+		{Id: "gone", DeleteAt: 1},
+	}, nil)
+
+	assert.Nil(p.updateState(func(state *State) error {
+		state.Programs["sales"] = NewProgram("sales")
+		state.Programs["sales"].ChannelID = "channel1"
+		return nil
+	}))
+	assert.Nil(p.syncProgramChannel("sales"))
+	assert.Equal([]string{"alice"}, p.state.Get().Programs["sales"].Users)
+
+	p.UserHasJoinedChannel(nil, &model.ChannelMember{ChannelId: "channel1", UserId: "bob"}, nil)
+	p.UserHasJoinedChannel(nil, &model.ChannelMember{ChannelId: "channel2", UserId: "carol"}, nil)
+	assert.Equal([]string{"alice", "bob"}, p.state.Get().Programs["sales"].Users)
+	assert.Empty(p.state.Get().Programs[defaultProgram].Users)
+
+	p.UserHasLeftChannel(nil, &model.ChannelMember{ChannelId: "channel1", UserId: "alice"}, nil)
+	assert.Equal([]string{"bob"}, p.state.Get().Programs["sales"].Users)
+
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Name: "sales-team"}, nil)
+	response, _ := p.ExecuteCommand(nil, &model.CommandArgs{UserId: "bob", Command: "/gather-plugin off sales"})
+	assert.Contains(response.Text, "~sales-team")
+	assert.Equal([]string{"bob"}, p.state.Get().Programs["sales"].Users)
+
+	api.AssertNotCalled(t, "GetUser", "carol")
+}
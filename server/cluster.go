@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// roundLeaseKey prefixes the lease held by the server of the cluster that is running a round of a program
+const roundLeaseKey = "roundLease_"
+
+// errRoundRunning is returned when another server is running a round of the program
+var errRoundRunning = errors.New("a round of the program is running")
+
+// roundLeaseDuration is how long a lease lasts if the server holding it dies before releasing it
+const roundLeaseDuration = 15 * time.Minute
+
+// leaseAPI is the part of the plugin API the round lease needs
+type leaseAPI interface {
+	KVGet(key string) ([]byte, *model.AppError)
+	KVCompareAndSet(key string, oldValue, newValue []byte) (bool, *model.AppError)
+	KVCompareAndDelete(key string, oldValue []byte) (bool, *model.AppError)
+}
+
+// roundLease is stored in roundLeaseKey while a server runs a round
+type roundLease struct {
+	ID        string `json:"id"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// acquireLease takes the lease if nobody holds it or it expired, it returns the stored lease to release it
+func acquireLease(api leaseAPI, key string, duration time.Duration) ([]byte, bool, error) {
+	current, appErr := api.KVGet(key)
+	if appErr != nil {
+		return nil, false, appErr
+	}
+
+	if current != nil {
+		lease := roundLease{}
+		if err := json.Unmarshal(current, &lease); err == nil && lease.ExpiresAt > model.GetMillis() {
+			return nil, false, nil
+		}
+	}
+
+	data, err := json.Marshal(roundLease{
+		ID:        model.NewId(),
+		ExpiresAt: model.GetMillis() + int64(duration/time.Millisecond),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, appErr := api.KVCompareAndSet(key, current, data)
+	if appErr != nil {
+		return nil, false, appErr
+	}
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	return data, true, nil
+}
+
+// releaseLease frees the lease unless it expired and someone else took it
+func releaseLease(api leaseAPI, key string, lease []byte) error {
+	if _, appErr := api.KVCompareAndDelete(key, lease); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// scheduleTolerance is how late a cron job may fire and still run the round of its tick
+const scheduleTolerance = 5 * time.Minute
+
+// roundIDFromTick identifies the round of a scheduled tick, all the servers compute the same id
+func roundIDFromTick(tick time.Time) string {
+	return tick.UTC().Format("20060102T1504Z")
+}
+
+// runScheduledRound is the cron job, every server of the cluster runs it but only one runs the round
+func (p *Plugin) runScheduledRound(name string) {
+	p.runRoundOnce(name, time.Now())
+}
+
+// runRoundOnce runs the round of the program scheduled at the time unless another server is running it or it already ran
+func (p *Plugin) runRoundOnce(name string, at time.Time) {
+	err := p.withRoundLease(name, func(program *Program) {
+		// the schedule may have been changed on another server of the cluster
+		tick, ok := scheduledTick(p.getCronSpecs(program), at)
+		if !ok {
+			p.API.LogWarn(fmt.Sprintf("Skipped the round of the program %s at %s, the program is not scheduled then", name, at.Format(time.RFC3339)))
+			return
+		}
+
+		if program.LastRoundAt >= timeToMillis(tick) {
+			return
+		}
+
+		p.startScheduledRound(program, roundIDFromTick(tick), tick)
+	})
+
+	if err == errRoundRunning {
+		p.API.LogDebug("Another server is running the round")
+	} else if err != nil && err != errProgramNotFound {
+		p.API.LogError(fmt.Sprintf("Failed to run the round of the program %s: %s", name, err.Error()))
+	}
+}
+
+// runManualRound runs a round of the program now on behalf of the admin, it returns the meetings started
+func (p *Plugin) runManualRound(name string, userID string) (History, error) {
+	records := History{}
+
+	err := p.withRoundLease(name, func(program *Program) {
+		now := time.Now()
+		roundID := "manual-" + roundIDFromTick(now) + "-" + model.NewId()[:8]
+
+		p.API.LogInfo(fmt.Sprintf("Round %s of the program %s triggered by %s", roundID, name, userID))
+		records = p.runMeetings(name, roundID, now, userID)
+	})
+
+	return records, err
+}
+
+// withRoundLease runs the round of the program while holding its lease, so no other server runs
+// a round of the program meanwhile
+func (p *Plugin) withRoundLease(name string, run func(program *Program)) error {
+	return p.withProgramLease(roundLeaseKey, name, run)
+}
+
+// withProgramLease runs the job of the program while holding the lease of the key prefix, so no
+// other server runs the job meanwhile. The state is reloaded first, another server may have changed it.
+func (p *Plugin) withProgramLease(keyPrefix string, name string, run func(program *Program)) error {
+	leaseKey := keyPrefix + name
+
+	lease, ok, err := acquireLease(p.API, leaseKey, roundLeaseDuration)
+	if err != nil {
+		return errors.Wrap(err, "failed to acquire the lease")
+	}
+
+	if !ok {
+		return errRoundRunning
+	}
+
+	defer func() {
+		if err := releaseLease(p.API, leaseKey, lease); err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to release the lease %s: %s", leaseKey, err.Error()))
+		}
+	}()
+
+	state, err := p.state.Reload()
+	if err != nil {
+		return errors.Wrap(err, "failed to load the state")
+	}
+
+	program, ok := state.Programs[name]
+	if !ok {
+		return errProgramNotFound
+	}
+
+	run(program)
+
+	return nil
+}
+
+// catchUpMissedRounds runs the last scheduled round of every program if it didn't run, e.g. the plugin
+// was disabled at that time
+func (p *Plugin) catchUpMissedRounds() {
+	for _, program := range p.state.Get().Programs {
+		if program.LastRoundAt == 0 {
+			continue
+		}
+
+		since := time.Unix(0, program.LastRoundAt*int64(time.Millisecond))
+		tick, missed := lastMissedTick(p.getCronSpecs(program), since, time.Now())
+
+		if missed {
+			p.API.LogInfo(fmt.Sprintf("The round of the program %s scheduled at %s didn't run, running it now", program.Name, tick.Format(time.RFC3339)))
+			p.runRoundOnce(program.Name, tick)
+		}
+	}
+}
+
+// scheduledTick returns the tick of the schedules the time belongs to, the latest one up to
+// scheduleTolerance before it. The @every schedules have no fixed ticks, their tick is the time
+// truncated to the minute.
+func scheduledTick(specs []string, at time.Time) (time.Time, bool) {
+	fixed := []string{}
+	every := false
+
+	for _, spec := range specs {
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := schedule.(cron.ConstantDelaySchedule); ok {
+			every = true
+		} else {
+			fixed = append(fixed, spec)
+		}
+	}
+
+	if tick, ok := lastMissedTick(fixed, at.Add(-scheduleTolerance), at); ok {
+		return tick, true
+	}
+
+	return at.Truncate(time.Minute), every
+}
+
+// lastMissedTick returns the latest tick of the schedules between since and now
+func lastMissedTick(specs []string, since time.Time, now time.Time) (time.Time, bool) {
+	var last time.Time
+	missed := false
+
+	for _, spec := range specs {
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			continue
+		}
+
+		// the limit avoids walking years of ticks of very frequent schedules
+		for next, i := schedule.Next(since), 0; !next.After(now) && i < 100000; next, i = schedule.Next(next), i+1 {
+			if next.After(last) {
+				last = next
+				missed = true
+			}
+		}
+	}
+
+	return last, missed
+}
+
+func timeToMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
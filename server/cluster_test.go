@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRoundLease(t *testing.T) {
+	assert := assert.New(t)
+	kv := newMemoryKV()
+
+	lease, ok, err := acquireLease(kv, roundLeaseKey, time.Minute)
+	assert.Nil(err)
+	assert.True(ok)
+
+	// another server can't run the round meanwhile
+	_, ok, err = acquireLease(kv, roundLeaseKey, time.Minute)
+	assert.Nil(err)
+	assert.False(ok)
+
+	assert.Nil(releaseLease(kv, roundLeaseKey, lease))
+
+	_, ok, err = acquireLease(kv, roundLeaseKey, -time.Minute)
+	assert.Nil(err)
+	assert.True(ok)
+
+	// the lease of a dead server expires
+	_, ok, err = acquireLease(kv, roundLeaseKey, time.Minute)
+	assert.Nil(err)
+	assert.True(ok)
+}
+
+func TestLastMissedTick(t *testing.T) {
+	assert := assert.New(t)
+	since := time.Date(2026, 10, 5, 9, 0, 0, 0, time.Local)
+
+	tick, missed := lastMissedTick([]string{"0 9 * * MON"}, since, since.Add(6*24*time.Hour))
+	assert.False(missed)
+	assert.True(tick.IsZero())
+
+	tick, missed = lastMissedTick([]string{"0 9 * * MON"}, since, since.Add(15*24*time.Hour))
+	assert.True(missed)
+	assert.Equal(since.Add(14*24*time.Hour), tick)
+
+	// the latest tick of all the schedules
+	tick, missed = lastMissedTick([]string{"0 9 * * MON", "0 9 * * WED", "invalid"}, since, since.Add(3*24*time.Hour))
+	assert.True(missed)
+	assert.Equal(since.Add(2*24*time.Hour), tick)
+
+	assert.Equal("20261005T0900Z", roundIDFromTick(time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC)))
+}
+
+func TestScheduledTick(t *testing.T) {
+	assert := assert.New(t)
+	tick := time.Date(2026, 10, 5, 9, 0, 0, 0, time.Local)
+
+	// a job that fires late still runs the round of its tick
+	scheduled, ok := scheduledTick([]string{"0 9 * * MON"}, tick.Add(70*time.Second))
+	assert.True(ok)
+	assert.Equal(tick, scheduled)
+
+	_, ok = scheduledTick([]string{"0 9 * * MON"}, tick.Add(time.Hour))
+	assert.False(ok)
+
+	scheduled, ok = scheduledTick([]string{"@every 2h"}, tick.Add(90*time.Second))
+	assert.True(ok)
+	assert.Equal(tick.Add(time.Minute), scheduled)
+}
+
+func TestRunRoundOnceWithEverySchedule(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	p.updateProgram(defaultProgram, func(program *Program) error {
+		program.Users = []string{"alice", "bob"}
+		program.Schedule = "@every 2h"
+		return nil
+	})
+
+	at := time.Now()
+	p.runRoundOnce(defaultProgram, at)
+
+	program, _ := p.getProgram(defaultProgram)
+	assert.Len(program.History, 1)
+	assert.Equal(roundIDFromTick(at.Truncate(time.Minute)), program.LastRoundID)
+
+	// the schedule changed on another server, the tick is skipped with a warning
+	api := p.API.(*plugintest.API)
+	api.On("LogWarn", mock.Anything).Return()
+	p.updateProgram(defaultProgram, func(program *Program) error {
+		program.Schedule = "0 0 1 1 *"
+		return nil
+	})
+
+	p.runRoundOnce(defaultProgram, at.Add(time.Hour))
+	program, _ = p.getProgram(defaultProgram)
+	assert.Len(program.History, 1)
+	api.AssertCalled(t, "LogWarn", mock.Anything)
+}
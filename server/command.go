@@ -5,16 +5,19 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/juanfran/mattermost-gather-users/server/utils"
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/robfig/cron/v3"
 )
 
 // ExecuteCommand run command
 func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
 	split := strings.Fields(args.Command)
-	adminCommands := []string{"add", "remove", "meetings", "set_meetings", "odd", "set_odd"}
+	adminCommands := []string{"add", "remove", "meetings", "set_meetings", "odd", "set_odd", "program", "preview", "run", "never_pair", "allow_pair", "pair_rules", "tag", "untag", "tags", "stats", "export", "import"}
 
 	caller, err := p.API.GetUser(args.UserId)
 	if err != nil {
@@ -27,30 +30,70 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 	}
 
 	msg := "This command is not supported"
+	name, params := parseProgram(p.state.Get(), split[2:])
+	program, ok := p.getProgram(name)
 
-	if split[1] == "on" {
-		p.addUser(args.UserId)
+	if unknown := unknownProgramArg(split[1], params); unknown != "" || !ok {
+		if unknown == "" {
+			unknown = name
+		}
 
-		_, ok := p.usersMeetings[args.UserId]
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("The program %s doesn't exist.", unknown),
+		}, nil
+	}
 
-		if !ok {
-			p.usersMeetings[args.UserId] = []string{}
-		}
+	if program.ChannelID != "" && utils.Contains([]string{"on", "off", "add", "remove"}, split[1]) {
+		return &model.CommandResponse{
+			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+			Text:         fmt.Sprintf("The users of the program %s are the members of %s, join or leave the channel instead. Use `/gather-plugin pause` to skip the meetings.", name, p.channelDisplayName(program.ChannelID)),
+		}, nil
+	}
 
+	if split[1] == "on" {
 		msg = "Gather plugin activate, wait for a meeting."
-	} else if split[1] == "off" {
-		p.removeUser(args.UserId)
 
+		if err := p.addUser(name, args.UserId); err != nil {
+			msg = "Failed to save list of users, contact your administrator."
+		}
+	} else if split[1] == "off" {
 		msg = "Gather plugin deactivate."
-	} else if split[1] == "pause" {
-		if utils.Contains(p.paused, args.UserId) {
-			p.paused = utils.Remove(p.paused, args.UserId)
-			msg = "Gather plugin unpaused."
+
+		if err := p.removeUser(name, args.UserId); err != nil {
+			msg = "Failed to save list of users, contact your administrator."
+		}
+	} else if split[1] == "pause" || split[1] == "skip" {
+		msg = p.executePauseCommand(split[1], program, args.UserId, params)
+	} else if split[1] == "last" {
+		var lines []string
+
+		for _, userID := range args.UserMentions {
+			user, err := p.API.GetUser(userID)
+			if err != nil {
+				return nil, err
+			}
+
+			record, ok := program.History.LastMeeting(args.UserId, userID)
+			if !ok {
+				lines = append(lines, fmt.Sprintf("You haven't met @%s yet.", user.Username))
+			} else if record.RoundID == legacyRoundID {
+				lines = append(lines, fmt.Sprintf("You met @%s before the meeting dates were recorded.", user.Username))
+			} else {
+				date := time.Unix(0, record.Timestamp*int64(time.Millisecond)).UTC().Format("2006-01-02")
+				lines = append(lines, fmt.Sprintf("You last met @%s on %s.", user.Username, date))
+			}
+		}
+
+		if len(lines) == 0 {
+			msg = "Mention the users you want to check, e.g. `/gather-plugin last @alice`."
 		} else {
-			p.paused = append(p.paused, args.UserId)
-			msg = "Gather plugin paused."
+			msg = strings.Join(lines, "\n")
 		}
-		p.persistPausedUsers()
+	} else if split[1] == "frequency" {
+		msg = p.executeFrequencyCommand(program, args.UserId, params)
+	} else if utils.Contains([]string{"block", "unblock", "blocks"}, split[1]) {
+		msg = p.executeBlockCommand(split[1], args, false)
 	} else if split[1] == "info" {
 		config := p.getConfiguration()
 
@@ -62,25 +105,34 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		}
 
 		var lines []string
-		for _, userId := range p.users {
+		locations := p.getUserLocations(program.PausedUsers(time.Now()))
+		for _, userId := range program.Users {
 			user, err := p.API.GetUser(userId)
 			if err != nil {
 				return nil, err
 			}
 
-			paused := ""
+			status := ""
 
-			if utils.Contains(p.paused, user.Id) {
-				paused = " paused"
+			if program.IsPaused(user.Id, time.Now()) {
+				status = " " + pauseText(program, user.Id, locations[user.Id])
 			}
 
-			lines = append(lines, fmt.Sprintf(" - %s %s (@%s)"+paused+"\n", user.FirstName, user.LastName, user.Username))
+			if frequency, ok := program.Frequencies[user.Id]; ok {
+				status += " " + frequency
+			}
+
+			lines = append(lines, fmt.Sprintf(" - %s %s (@%s)"+status+"\n", user.FirstName, user.LastName, user.Username))
 		}
 
 		sort.Strings(lines)
 
 		var msgBuilder strings.Builder
-		msgBuilder.WriteString("Users signed up for coffee meetings:\n")
+		if name == defaultProgram {
+			msgBuilder.WriteString("Users signed up for coffee meetings:\n")
+		} else {
+			msgBuilder.WriteString(fmt.Sprintf("Users signed up for coffee meetings of the program %s:\n", name))
+		}
 		for _, line := range lines {
 			msgBuilder.WriteString(line)
 		}
@@ -94,27 +146,58 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		}
 
 		if split[1] == "add" {
+			msg = "Add complete."
+
 			for _, v := range args.UserMentions {
-				p.addUser(v)
+				if err := p.addUser(name, v); err != nil {
+					msg = "Failed to save list of users."
+				}
 			}
-
-			msg = "Add complete."
 		} else if split[1] == "remove" {
+			msg = "Remove complete."
+
 			for _, v := range args.UserMentions {
-				user, _ := p.API.GetUser(v)
-				p.removeUser(user.Id)
+				if err := p.removeUser(name, v); err != nil {
+					msg = "Failed to save list of users."
+				}
+			}
+		} else if utils.Contains([]string{"tag", "untag", "tags"}, split[1]) {
+			// the tags are global, a tag named like a program is still a tag
+			msg = p.executeTagCommand(split[1], args, split[2:])
+		} else if utils.Contains([]string{"never_pair", "allow_pair", "pair_rules"}, split[1]) {
+			msg = p.executeBlockCommand(split[1], args, true)
+		} else if split[1] == "run" {
+			p.sendRunConfirmation(args, program)
+
+			return &model.CommandResponse{}, nil
+		} else if split[1] == "export" {
+			format := formatJSON
+			if utils.Contains(params, formatCSV) {
+				format = formatCSV
 			}
 
-			msg = "Remove complete."
+			msg = "The export was sent to you in a direct message."
+			if err := p.sendExport(args.UserId, format); err != nil {
+				p.API.LogError(fmt.Sprintf("Failed to send the export: %s", err.Error()))
+				msg = "Failed to export the state."
+			}
+		} else if split[1] == "import" {
+			msg = "Send the JSON or CSV file of an export to the bot in a direct message with the message `import merge` or `import replace`."
+		} else if split[1] == "stats" {
+			msg = p.statsText(name, computeStats(program, time.Now()))
+		} else if split[1] == "preview" {
+			msg = p.previewText(name, p.previewRound(program))
+		} else if split[1] == "program" {
+			msg = p.executeProgramCommand(args)
 		} else if split[1] == "meetings" {
-			mettings := p.usersMeetingsByUsername()
+			mettings := p.usersMeetingsByUsername(program)
 			output, _ := json.Marshal(mettings)
 
 			msg = "```" + string(output) + "```"
 		} else if split[1] == "odd" {
 			var users []string
 
-			for _, userId := range p.oddUserTurn {
+			for _, userId := range program.OddUserTurn {
 				user, err := p.API.GetUser(userId)
 				if err != nil {
 					return nil, err
@@ -124,9 +207,9 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 
 			output, _ := json.Marshal(users)
 
-			msg = "```" + string(output) + "```"
+			msg = p.oddUserPolicyInfo() + "\n```" + string(output) + "```"
 		} else if split[1] == "set_odd" {
-			byt := []byte(split[2])
+			byt := []byte(strings.Join(params, " "))
 			var dat []string
 			var oddUserTurn []string
 
@@ -140,20 +223,26 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 					}
 				}
 
-				p.oddUserTurn = oddUserTurn
-				p.persistOddUserTurn()
+				err := p.updateProgram(name, func(program *Program) error {
+					program.OddUserTurn = oddUserTurn
+					return nil
+				})
 
-				msg = "oddUserTurn setted"
+				if err != nil {
+					msg = "Failed to save oddUserTurn."
+				} else {
+					msg = "oddUserTurn setted\n" + p.oddUserPolicyInfo()
+				}
 			}
 		} else if split[1] == "set_meetings" {
-			byt := []byte(split[2])
+			byt := []byte(strings.Join(params, " "))
 			dat := make(map[string][]string)
 			mettings := make(map[string][]string)
 
 			if err := json.Unmarshal(byt, &dat); err != nil {
 				msg += "\nFailed parsing json."
 			} else {
-				for _, userId := range p.users {
+				for _, userId := range program.Users {
 
 					_, ok := mettings[userId]
 					if !ok {
@@ -170,18 +259,18 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 					}
 				}
 
-				p.usersMeetings = mettings
-				p.persistMeetings()
+				err := p.updateProgram(name, func(program *Program) error {
+					program.History = historyFromAdjacency(mettings)
+					return nil
+				})
 
-				msg = "Meetings setted"
+				if err != nil {
+					msg = "Failed to save meetings."
+				} else {
+					msg = "Meetings setted"
+				}
 			}
 		}
-	} else if split[1] == "on" || split[1] == "off" {
-		// Save users when list changed
-		err := p.persistUsers()
-		if err != nil {
-			msg += "\nFailed to save list of users, contact your administrator."
-		}
 	}
 
 	return &model.CommandResponse{
@@ -189,3 +278,251 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		Text:         msg,
 	}, nil
 }
+
+func (p *Plugin) oddUserPolicyInfo() string {
+	config := p.getConfiguration()
+	policy := config.getOddUserPolicy()
+
+	if policy == oddPolicyVolunteer {
+		if p.getVolunteerID() == "" {
+			return "Odd user policy: volunteer, but the volunteer user is not valid so the odd user skips the round."
+		}
+
+		return fmt.Sprintf("Odd user policy: volunteer, the odd user meets @%s.", strings.TrimPrefix(config.OddUserVolunteer, "@"))
+	}
+
+	if policy == oddPolicyTrio {
+		return "Odd user policy: trio, the odd user joins an existing pair."
+	}
+
+	return "Odd user policy: skip, the odd user doesn't meet anyone this round."
+}
+
+// unknownProgramArg returns the argument that should have been a program but isn't one. The
+// arguments of the commands are mentions, a frequency, a pause or JSON, any other argument is a program name.
+func unknownProgramArg(command string, params []string) string {
+	switch command {
+	case "program", "set_meetings", "set_odd", "tag", "untag", "export", "import":
+		return ""
+	case "frequency":
+		if len(params) > 0 {
+			params = params[1:]
+		}
+	case "pause", "skip":
+		params = params[pauseArgsCount(command, params):]
+	}
+
+	for _, param := range params {
+		if !strings.HasPrefix(param, "@") {
+			return param
+		}
+	}
+
+	return ""
+}
+
+// executeProgramCommand runs the program subcommands and returns the message for the admin
+func (p *Plugin) executeProgramCommand(args *model.CommandArgs) string {
+	command := args.Command
+	split := strings.Fields(command)
+	usage := "Usage: `/gather-plugin program list|create <name>|delete <name>|schedule <name> [cron]|text <name> [text]|channel <name> [~channel|off]`"
+
+	if len(split) < 3 {
+		return usage
+	}
+
+	if split[2] == "list" {
+		var msgBuilder strings.Builder
+		msgBuilder.WriteString("Programs:\n")
+
+		state := p.state.Get()
+		for _, name := range state.ProgramNames() {
+			program := state.Programs[name]
+			schedule := strings.Join(p.getCronSpecs(program), ", ")
+			channel := ""
+			if program.ChannelID != "" {
+				channel = ", members of " + p.channelDisplayName(program.ChannelID)
+			}
+			msgBuilder.WriteString(fmt.Sprintf(" - %s: %d users, schedule `%s`%s\n", name, len(program.Users), schedule, channel))
+		}
+
+		return msgBuilder.String()
+	}
+
+	if len(split) < 4 {
+		return usage
+	}
+
+	name := split[3]
+	// the schedule and the text keep the spaces and new lines of the command
+	value := strings.TrimSpace(commandRest(command, 4))
+
+	switch split[2] {
+	case "create":
+		if err := validateProgramName(name); err != nil {
+			return fmt.Sprintf("Invalid program name: %s.", err.Error())
+		}
+
+		exists := false
+		err := p.updateState(func(state *State) error {
+			if _, exists = state.Programs[name]; !exists {
+				state.Programs[name] = NewProgram(name)
+			}
+			return nil
+		})
+
+		if err != nil {
+			return "Failed to save the program."
+		}
+
+		if exists {
+			return fmt.Sprintf("The program %s already exists.", name)
+		}
+
+		p.addCronFunc()
+
+		return fmt.Sprintf("Program %s created, users can join it with `/gather-plugin on %s`.", name, name)
+	case "delete":
+		if name == defaultProgram {
+			return "The default program can't be deleted."
+		}
+
+		err := p.updateState(func(state *State) error {
+			if _, ok := state.Programs[name]; !ok {
+				return errProgramNotFound
+			}
+
+			delete(state.Programs, name)
+			return nil
+		})
+
+		if err == errProgramNotFound {
+			return fmt.Sprintf("The program %s doesn't exist.", name)
+		}
+
+		if err != nil {
+			return "Failed to delete the program."
+		}
+
+		p.addCronFunc()
+
+		return fmt.Sprintf("Program %s deleted.", name)
+	case "schedule":
+		if value != "" {
+			for _, spec := range strings.Split(value, ",") {
+				if _, err := cron.ParseStandard(spec); err != nil {
+					return fmt.Sprintf("Invalid cron expression `%s`: %s.", spec, err.Error())
+				}
+			}
+		}
+
+		err := p.updateProgram(name, func(program *Program) error {
+			program.Schedule = value
+			return nil
+		})
+
+		if err == errProgramNotFound {
+			return fmt.Sprintf("The program %s doesn't exist.", name)
+		}
+
+		if err != nil {
+			return "Failed to save the program."
+		}
+
+		p.addCronFunc()
+
+		if value == "" {
+			return fmt.Sprintf("The program %s follows the plugin recurrence.", name)
+		}
+
+		return fmt.Sprintf("The program %s meets on `%s`.", name, value)
+	case "channel":
+		channelID := args.ChannelId
+		if value == "off" {
+			channelID = ""
+		} else if len(args.ChannelMentions) > 1 {
+			return "Mention only one channel."
+		}
+
+		for _, mentionedID := range args.ChannelMentions {
+			channelID = mentionedID
+		}
+
+		err := p.updateProgram(name, func(program *Program) error {
+			program.ChannelID = channelID
+			return nil
+		})
+
+		if err == errProgramNotFound {
+			return fmt.Sprintf("The program %s doesn't exist.", name)
+		}
+
+		if err != nil {
+			return "Failed to save the program."
+		}
+
+		if channelID == "" {
+			return fmt.Sprintf("The program %s is not bound to a channel anymore, its users stay until they leave it.", name)
+		}
+
+		if err := p.syncProgramChannel(name); err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to sync the program %s with its channel: %s", name, err.Error()))
+			return fmt.Sprintf("The program %s is bound to %s but its members could not be added, contact your administrator.", name, p.channelDisplayName(channelID))
+		}
+
+		return fmt.Sprintf("The users of the program %s are now the members of %s.", name, p.channelDisplayName(channelID))
+	case "text":
+		if err := validateIntroTemplate(value); err != nil {
+			return fmt.Sprintf("Invalid initial text: %s.", err.Error())
+		}
+
+		err := p.updateProgram(name, func(program *Program) error {
+			program.InitText = value
+			return nil
+		})
+
+		if err == errProgramNotFound {
+			return fmt.Sprintf("The program %s doesn't exist.", name)
+		}
+
+		if err != nil {
+			return "Failed to save the program."
+		}
+
+		if value == "" {
+			return fmt.Sprintf("The program %s uses the plugin initial text.", name)
+		}
+
+		return fmt.Sprintf("Initial text of the program %s saved.", name)
+	}
+
+	return usage
+}
+
+// commandRest returns the command without its first n words
+func commandRest(command string, n int) string {
+	rest := command
+
+	for i := 0; i < n; i++ {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end == -1 {
+			return ""
+		}
+
+		rest = rest[end:]
+	}
+
+	return rest
+}
+
+// channelDisplayName returns the channel as a mention, or its id if it can't be loaded
+func (p *Plugin) channelDisplayName(channelID string) string {
+	channel, err := p.API.GetChannel(channelID)
+	if err != nil {
+		return channelID
+	}
+
+	return "~" + channel.Name
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -23,6 +26,20 @@ type configuration struct {
 	InitText             string
 	FirstMeeting         bool
 	AllowInfoForEveryone bool
+	Matching             string
+	GroupSize            string
+	OddUserPolicy        string
+	OddUserVolunteer     string
+	HistoryWindow        string
+	HistoryWindowUnit    string
+	MixingAttribute      string
+	MixingStrength       string
+	WorkingHours         string
+	MinimumOverlap       string
+	ConfirmRounds        bool
+	ConfirmCutoff        string
+	FollowUpDays         string
+	RepairUnmet          bool
 }
 
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
@@ -32,6 +49,113 @@ func (c *configuration) Clone() *configuration {
 	return &clone
 }
 
+// getGroupSize returns how many users meet together, pairs by default
+func (c *configuration) getGroupSize() int {
+	size, err := strconv.Atoi(c.GroupSize)
+	if err != nil || size < 2 {
+		return 2
+	}
+
+	return size
+}
+
+// getOddUserPolicy returns what to do with the odd user, by default the user skips the round
+func (c *configuration) getOddUserPolicy() string {
+	if c.OddUserPolicy == oddPolicyTrio || c.OddUserPolicy == oddPolicyVolunteer {
+		return c.OddUserPolicy
+	}
+
+	return oddPolicySkip
+}
+
+// getMixing returns the user attribute used to mix users from different groups and how strongly,
+// the attribute is empty if the users are not mixed
+func (c *configuration) getMixing() (string, string) {
+	switch c.MixingAttribute {
+	case mixingByPosition, mixingByTeam, mixingByTag:
+	default:
+		return "", ""
+	}
+
+	switch c.MixingStrength {
+	case mixingLow, mixingHigh:
+		return c.MixingAttribute, c.MixingStrength
+	}
+
+	return c.MixingAttribute, mixingMedium
+}
+
+// getHistoryWindow returns how many rounds or days a meeting counts when pairing users, 0 means forever
+func (c *configuration) getHistoryWindow() (int, string) {
+	window, err := strconv.Atoi(strings.TrimSpace(c.HistoryWindow))
+	if err != nil || window < 0 {
+		window = 0
+	}
+
+	if c.HistoryWindowUnit == "days" {
+		return window, "days"
+	}
+
+	return window, "rounds"
+}
+
+// getWorkingHours returns the start and the end of the working hours in minutes of the local day,
+// 09:00-17:00 if they are not valid
+func (c *configuration) getWorkingHours() (int, int) {
+	hours := strings.Split(strings.TrimSpace(c.WorkingHours), "-")
+	if len(hours) == 2 {
+		start, startErr := time.Parse("15:04", strings.TrimSpace(hours[0]))
+		end, endErr := time.Parse("15:04", strings.TrimSpace(hours[1]))
+
+		if startErr == nil && endErr == nil && start.Before(end) {
+			return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute()
+		}
+	}
+
+	return 9 * 60, 17 * 60
+}
+
+// getMinimumOverlap returns the minutes the working hours of the users should overlap, 0 means the
+// timezones are ignored
+func (c *configuration) getMinimumOverlap() int {
+	hours, err := strconv.ParseFloat(strings.TrimSpace(c.MinimumOverlap), 64)
+	if err != nil || hours < 0 {
+		return 0
+	}
+
+	return int(hours * 60)
+}
+
+// getConfirmCutoff returns how long the users have to confirm they join a round, 24 hours by default
+func (c *configuration) getConfirmCutoff() time.Duration {
+	hours, err := strconv.ParseFloat(strings.TrimSpace(c.ConfirmCutoff), 64)
+	if err != nil || hours <= 0 {
+		return 24 * time.Hour
+	}
+
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// getFollowUpDays returns how many days after a meeting its follow-up is posted, 0 means never
+func (c *configuration) getFollowUpDays() int {
+	days, err := strconv.Atoi(strings.TrimSpace(c.FollowUpDays))
+	if err != nil || days < 0 {
+		return 0
+	}
+
+	return days
+}
+
+// followUpAt returns when the follow-up of a meeting started at the time is due, 0 if there are no follow-ups
+func (c *configuration) followUpAt(timestamp int64) int64 {
+	days := c.getFollowUpDays()
+	if days == 0 {
+		return 0
+	}
+
+	return timestamp + int64(days)*int64(24*time.Hour/time.Millisecond)
+}
+
 // getConfiguration retrieves the active configuration under lock, making it safe to use
 // concurrently. The active configuration may change underneath the client of this method, but
 // the struct returned by this API call is considered immutable.
@@ -82,12 +206,17 @@ func (p *Plugin) OnConfigurationChange() error {
 		return errors.Wrap(err, "failed to load plugin configuration")
 	}
 
+	// a broken template is rejected, the previous configuration stays
+	if err := validateIntroTemplate(configuration.InitText); err != nil {
+		return errors.Wrap(err, "invalid initial text")
+	}
+
 	dirtyCron := false
 
 	if p.cron != nil {
 		conf := p.getConfiguration()
 
-		if conf.Cron != configuration.Cron || conf.CustomCron != configuration.CustomCron {
+		if conf.Cron != configuration.Cron || conf.CustomCron != configuration.CustomCron || conf.ConfirmRounds != configuration.ConfirmRounds || conf.FollowUpDays != configuration.FollowUpDays {
 			dirtyCron = true
 		}
 	} else {
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// confirmActionPath is the API route of the buttons of the round invitations
+const confirmActionPath = "actions/confirm"
+
+// pendingRoundsSchedule is how often the servers look for rounds whose cutoff passed
+const pendingRoundsSchedule = "@every 1m"
+
+// errRoundClosed is returned when a user answers an invitation after the cutoff
+var errRoundClosed = errors.New("the round is closed")
+
+// PendingRound is a round waiting for the users to confirm they join it, the users that confirmed
+// are paired after the cutoff
+type PendingRound struct {
+	RoundID   string   `json:"roundId"`
+	At        int64    `json:"at"`
+	CutoffAt  int64    `json:"cutoffAt"`
+	Invited   []string `json:"invited"`
+	Confirmed []string `json:"confirmed"`
+}
+
+// startScheduledRound runs the round or, if the users confirm the rounds, invites the users to it.
+// A round still waiting for confirmations runs first.
+func (p *Plugin) startScheduledRound(program *Program, roundID string, at time.Time) {
+	if pending := program.PendingRound; pending != nil {
+		p.runMeetings(program.Name, pending.RoundID, time.Unix(0, pending.At*int64(time.Millisecond)), "")
+	}
+
+	if !p.getConfiguration().ConfirmRounds {
+		p.runMeetings(program.Name, roundID, at, "")
+		return
+	}
+
+	p.askConfirmations(program.Name, roundID, at)
+}
+
+// askConfirmations saves the round as pending and invites the active users to it, the users whose
+// pause ended are resumed first so they are invited too
+func (p *Plugin) askConfirmations(name string, roundID string, at time.Time) {
+	var pending *PendingRound
+
+	p.resumeUsers(name, at, false)
+
+	err := p.updateProgram(name, func(program *Program) error {
+		invited := utils.Intersect(program.DueUsers(at), program.ActiveUsers(at))
+		pending = &PendingRound{
+			RoundID:   roundID,
+			At:        timeToMillis(at),
+			CutoffAt:  timeToMillis(at.Add(p.getConfiguration().getConfirmCutoff())),
+			Invited:   invited,
+			Confirmed: []string{},
+		}
+
+		program.PendingRound = pending
+		program.LastRoundAt = timeToMillis(at)
+		return nil
+	})
+
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to save the round %s: %s", roundID, err.Error()))
+		return
+	}
+
+	for _, userID := range pending.Invited {
+		channel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
+		if appErr != nil {
+			p.API.LogError(fmt.Sprintf("Failed to get the direct channel of %s: %s", userID, appErr.Error()))
+			continue
+		}
+
+		post := p.invitationPost(name, pending, userID, false)
+		post.ChannelId = channel.Id
+
+		if _, appErr := p.API.CreatePost(post); appErr != nil {
+			p.API.LogError(fmt.Sprintf("Failed to invite %s to the round: %s", userID, appErr.Error()))
+		}
+	}
+}
+
+// invitationPost asks the user to join the round, with the answer of the user if they answered
+func (p *Plugin) invitationPost(name string, pending *PendingRound, userID string, answered bool) *model.Post {
+	url := fmt.Sprintf("/plugins/%s%s%s", manifest.Id, apiPrefix, confirmActionPath)
+	cutoff := time.Unix(0, pending.CutoffAt*int64(time.Millisecond)).UTC().Format("2006-01-02 15:04 MST")
+
+	title := "Join the next round of coffee meetings?"
+	if name != defaultProgram {
+		title = fmt.Sprintf("Join the next round of the program %s?", name)
+	}
+
+	text := fmt.Sprintf("Answer before %s, only the users that join are paired.", cutoff)
+	if answered && utils.Contains(pending.Confirmed, userID) {
+		text = fmt.Sprintf("You joined the round, you will meet someone after %s. You can still skip it.", cutoff)
+	} else if answered {
+		text = fmt.Sprintf("You skip this round. You can still join it before %s.", cutoff)
+	}
+
+	post := &model.Post{UserId: p.botUserID}
+	action := func(id string, label string, join bool) *model.PostAction {
+		return &model.PostAction{
+			Id:   id,
+			Name: label,
+			Integration: &model.PostActionIntegration{
+				URL:     url,
+				Context: map[string]interface{}{"program": name, "round_id": pending.RoundID, "join": join},
+			},
+		}
+	}
+
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{{
+		Title:   title,
+		Text:    text,
+		Actions: []*model.PostAction{action("join", "Yes", true), action("skip", "Skip", false)},
+	}})
+
+	return post
+}
+
+// answerInvitation saves whether the user joins the pending round of the program
+func (p *Plugin) answerInvitation(name string, roundID string, userID string, join bool) (*PendingRound, error) {
+	var pending *PendingRound
+
+	err := p.updateProgram(name, func(program *Program) error {
+		pending = program.PendingRound
+		if pending == nil || pending.RoundID != roundID || pending.CutoffAt <= model.GetMillis() || !utils.Contains(pending.Invited, userID) {
+			return errRoundClosed
+		}
+
+		pending.Confirmed = utils.Remove(pending.Confirmed, userID)
+		if join {
+			pending.Confirmed = append(pending.Confirmed, userID)
+		}
+		return nil
+	})
+
+	return pending, err
+}
+
+// runPendingRounds is the cron job that runs the rounds whose cutoff passed, every server of the
+// cluster runs it but only one runs each round
+func (p *Plugin) runPendingRounds() {
+	for _, program := range p.state.Get().Programs {
+		if program.PendingRound == nil || program.PendingRound.CutoffAt > model.GetMillis() {
+			continue
+		}
+
+		err := p.withRoundLease(program.Name, func(program *Program) {
+			pending := program.PendingRound
+			if pending == nil || pending.CutoffAt > model.GetMillis() {
+				return
+			}
+
+			p.runMeetings(program.Name, pending.RoundID, time.Unix(0, pending.At*int64(time.Millisecond)), "")
+		})
+
+		if err == errRoundRunning {
+			p.API.LogDebug("Another server is running the round")
+		} else if err != nil && err != errProgramNotFound {
+			p.API.LogError(fmt.Sprintf("Failed to run the round of the program %s: %s", program.Name, err.Error()))
+		}
+	}
+}
+
+// apiConfirmAction saves the answer of the user to the invitation of a round
+func (p *Plugin) apiConfirmAction(req *apiRequest) {
+	if req.r.Method != http.MethodPost {
+		writeError(req.w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+
+	action := model.PostActionIntegrationRequest{}
+	if err := json.NewDecoder(req.r.Body).Decode(&action); err != nil {
+		writeError(req.w, http.StatusBadRequest, fmt.Sprintf("Failed parsing json: %s.", err.Error()))
+		return
+	}
+
+	name, _ := action.Context["program"].(string)
+	roundID, _ := action.Context["round_id"].(string)
+	join, _ := action.Context["join"].(bool)
+
+	pending, err := p.answerInvitation(name, roundID, req.userID, join)
+	if err == errRoundClosed || err == errProgramNotFound {
+		writeJSON(req.w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "The round is closed, wait for the next one."})
+		return
+	}
+
+	if err != nil {
+		writeJSON(req.w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "Failed to save your answer, contact your administrator."})
+		return
+	}
+
+	// the buttons stay so the user can change the answer until the cutoff
+	writeJSON(req.w, http.StatusOK, &model.PostActionIntegrationResponse{Update: p.invitationPost(name, pending, req.userID, true)})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestConfirmedRounds(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	p.setConfiguration(&configuration{ConfirmRounds: true, ConfirmCutoff: "2"})
+
+	answer := func(userID string, roundID string, join bool) *model.PostActionIntegrationResponse {
+		body, _ := json.Marshal(model.PostActionIntegrationRequest{
+			UserId:  userID,
+			Context: map[string]interface{}{"program": defaultProgram, "round_id": roundID, "join": join},
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/actions/confirm", strings.NewReader(string(body)))
+		r.Header.Set("Mattermost-User-Id", userID)
+		p.ServeHTTP(nil, w, r)
+
+		response := &model.PostActionIntegrationResponse{}
+		assert.Nil(json.Unmarshal(w.Body.Bytes(), response))
+		return response
+	}
+
+	for _, userID := range []string{"alice", "bob", "carol", "dave"} {
+		p.addUser(defaultProgram, userID)
+	}
+
+	at := time.Now().Truncate(time.Minute)
+	program, _ := p.getProgram(defaultProgram)
+	p.startScheduledRound(program, "r1", at)
+
+	program, _ = p.getProgram(defaultProgram)
+	assert.Empty(program.History)
+	assert.Equal("r1", program.PendingRound.RoundID)
+	assert.Equal(timeToMillis(at.Add(2*time.Hour)), program.PendingRound.CutoffAt)
+	assert.ElementsMatch([]string{"alice", "bob", "carol", "dave"}, program.PendingRound.Invited)
+
+	response := answer("alice", "r1", true)
+	assert.Contains(response.Update.Attachments()[0].Text, "You joined the round")
+	answer("bob", "r1", true)
+	answer("carol", "r1", true)
+	response = answer("carol", "r1", false)
+	assert.Contains(response.Update.Attachments()[0].Text, "You skip this round")
+	assert.Equal("The round is closed, wait for the next one.", answer("dave", "r0", true).EphemeralText)
+
+	// nothing runs before the cutoff
+	p.runPendingRounds()
+	program, _ = p.getProgram(defaultProgram)
+	assert.Equal([]string{"alice", "bob"}, program.PendingRound.Confirmed)
+
+	p.updateProgram(defaultProgram, func(program *Program) error {
+		program.PendingRound.CutoffAt = timeToMillis(time.Now().Add(-time.Minute))
+		return nil
+	})
+	p.runPendingRounds()
+
+	program, _ = p.getProgram(defaultProgram)
+	assert.Nil(program.PendingRound)
+	assert.Len(program.History, 1)
+	assert.ElementsMatch([]string{"alice", "bob"}, program.History[0].Participants)
+	assert.Equal("r1", program.History[0].RoundID)
+	assert.Equal("The round is closed, wait for the next one.", answer("dave", "r1", true).EphemeralText)
+}
+
+func TestConfirmedRoundsResumeExpiredPauses(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	p.setConfiguration(&configuration{ConfirmRounds: true, ConfirmCutoff: "2"})
+
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		p.addUser(defaultProgram, userID)
+	}
+
+	// carol's pause ends after the round was scheduled but before the invitations are sent
+	at := time.Now().Truncate(time.Minute)
+	p.setPause(defaultProgram, "carol", at.Add(-time.Second), 0)
+
+	program, _ := p.getProgram(defaultProgram)
+	p.startScheduledRound(program, "r1", at)
+
+	program, _ = p.getProgram(defaultProgram)
+	assert.Empty(program.Paused)
+	assert.ElementsMatch([]string{"alice", "bob", "carol"}, program.PendingRound.Invited)
+
+	p.API.(*plugintest.API).AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "dm_carol" && post.Message == "Your pause is over, you will meet someone in the next round."
+	}))
+}
+
+func TestConfirmedRoundsOnlyPairConfirmedUsers(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	// everyone already met, c skipped the round
+	program := NewProgram(defaultProgram)
+	program.Users = []string{"a", "b", "c"}
+	program.History = History{
+		{RoundID: "r0", Timestamp: 1, Participants: []string{"a", "c"}},
+		{RoundID: "r0", Timestamp: 1, Participants: []string{"b", "c"}},
+		{RoundID: "r0", Timestamp: 1, Participants: []string{"a", "b"}},
+	}
+	program.PendingRound = &PendingRound{RoundID: "r1", Invited: []string{"a", "b", "c"}, Confirmed: []string{"a", "b"}}
+
+	for i := 0; i < 20; i++ {
+		result := p.getMatcher().Match(p.roundInput(program, "r1"))
+		assert.Len(result.Groups, 1)
+		assert.ElementsMatch([]string{"a", "b"}, matchedUsers(result.Groups))
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// feedbackActionPath is the API route of the buttons of the meeting follow-ups
+const feedbackActionPath = "actions/feedback"
+
+// followUpsSchedule is how often the servers look for the meetings whose follow-up is due
+const followUpsSchedule = "@every 10m"
+
+// followUpLeaseKey prefixes the lease held by the server of the cluster that is sending the follow-ups
+// of a program, separate from the round lease so the follow-ups don't hold back a round
+const followUpLeaseKey = "followUpLease_"
+
+// Answers to the follow-up of a meeting
+const (
+	feedbackMet    = "met"
+	feedbackNotMet = "not_met"
+)
+
+var (
+	errMeetingNotFound = errors.New("meeting not found")
+	errNotParticipant  = errors.New("the user is not a participant of the meeting")
+)
+
+// MeetingFeedback is the answer of a participant to the follow-up of a meeting
+type MeetingFeedback struct {
+	Status    string `json:"status"`
+	Rating    int    `json:"rating,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// notHappened returns true if some participant said the meeting didn't happen and nobody said it did
+func (record *MeetingRecord) notHappened() bool {
+	notMet := false
+
+	for _, feedback := range record.Feedback {
+		if feedback.Status == feedbackMet {
+			return false
+		}
+
+		if feedback.Status == feedbackNotMet {
+			notMet = true
+		}
+	}
+
+	return notMet
+}
+
+// WithoutUnmet returns the history without the meetings that didn't happen
+func (h History) WithoutUnmet() History {
+	history := History{}
+
+	for _, record := range h {
+		if !record.notHappened() {
+			history = append(history, record)
+		}
+	}
+
+	return history
+}
+
+// findRecord returns the meeting of the round in the channel
+func (h History) findRecord(roundID string, channelID string) (*MeetingRecord, bool) {
+	for _, record := range h {
+		if record.RoundID == roundID && record.ChannelID == channelID {
+			return record, true
+		}
+	}
+
+	return nil, false
+}
+
+// sendFollowUps is the cron job that asks the users whether their meetings happened, every server of
+// the cluster runs it but only one asks about each meeting
+func (p *Plugin) sendFollowUps() {
+	for _, program := range p.state.Get().Programs {
+		if len(dueFollowUps(program.History)) == 0 {
+			continue
+		}
+
+		err := p.withProgramLease(followUpLeaseKey, program.Name, func(program *Program) {
+			for _, record := range dueFollowUps(program.History) {
+				p.sendFollowUp(program.Name, record)
+			}
+		})
+
+		if err == errRoundRunning {
+			p.API.LogDebug("Another server is sending the follow-ups")
+		} else if err != nil && err != errProgramNotFound {
+			p.API.LogError(fmt.Sprintf("Failed to send the follow-ups of the program %s: %s", program.Name, err.Error()))
+		}
+	}
+}
+
+// dueFollowUps returns the meetings whose follow-up is due and wasn't sent yet
+func dueFollowUps(history History) History {
+	due := History{}
+	now := model.GetMillis()
+
+	for _, record := range history {
+		if record.FollowUpAt != 0 && record.FollowUpAt <= now && record.FollowUpPostID == "" {
+			due = append(due, record)
+		}
+	}
+
+	return due
+}
+
+// sendFollowUp posts the follow-up of the meeting in its channel
+func (p *Plugin) sendFollowUp(name string, record *MeetingRecord) {
+	url := fmt.Sprintf("/plugins/%s%s%s", manifest.Id, apiPrefix, feedbackActionPath)
+	context := func(answer string) map[string]interface{} {
+		return map[string]interface{}{"program": name, "round_id": record.RoundID, "channel_id": record.ChannelID, "answer": answer}
+	}
+
+	options := []*model.PostActionOptions{}
+	for rating := 1; rating <= 5; rating++ {
+		options = append(options, &model.PostActionOptions{Text: strconv.Itoa(rating), Value: strconv.Itoa(rating)})
+	}
+
+	post := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: record.ChannelID,
+	}
+
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{{
+		Title: "Did you meet?",
+		Text:  "Tell us how your meeting went, it helps to pair you better.",
+		Actions: []*model.PostAction{
+			{Id: "met", Name: "We met", Integration: &model.PostActionIntegration{URL: url, Context: context(feedbackMet)}},
+			{Id: "notmet", Name: "Didn't happen", Integration: &model.PostActionIntegration{URL: url, Context: context(feedbackNotMet)}},
+			{Id: "rate", Name: "Rate 1-5", Type: model.POST_ACTION_TYPE_SELECT, Options: options, Integration: &model.PostActionIntegration{URL: url, Context: context("rate")}},
+		},
+	}})
+
+	post, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		p.API.LogError(fmt.Sprintf("Failed to post the follow-up of the meeting: %s", appErr.Error()))
+		return
+	}
+
+	err := p.updateProgram(name, func(program *Program) error {
+		if record, ok := program.History.findRecord(record.RoundID, record.ChannelID); ok {
+			record.FollowUpPostID = post.Id
+		}
+		return nil
+	})
+
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to save the follow-up of the meeting: %s", err.Error()))
+	}
+}
+
+// saveFeedback stores the answer of a participant to the follow-up of a meeting, a rating means
+// the meeting happened
+func (p *Plugin) saveFeedback(name string, roundID string, channelID string, userID string, status string, rating int) error {
+	return p.updateProgram(name, func(program *Program) error {
+		record, ok := program.History.findRecord(roundID, channelID)
+		if !ok {
+			return errMeetingNotFound
+		}
+
+		if !utils.Contains(record.Participants, userID) {
+			return errNotParticipant
+		}
+
+		if record.Feedback == nil {
+			record.Feedback = make(map[string]*MeetingFeedback)
+		}
+
+		feedback, ok := record.Feedback[userID]
+		if !ok {
+			feedback = &MeetingFeedback{}
+			record.Feedback[userID] = feedback
+		}
+
+		feedback.Status = status
+		feedback.Timestamp = model.GetMillis()
+		if rating > 0 {
+			feedback.Rating = rating
+		}
+		return nil
+	})
+}
+
+// apiFeedbackAction saves the answer of a participant to the follow-up of a meeting
+func (p *Plugin) apiFeedbackAction(req *apiRequest) {
+	if req.r.Method != http.MethodPost {
+		writeError(req.w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+
+	action := model.PostActionIntegrationRequest{}
+	if err := json.NewDecoder(req.r.Body).Decode(&action); err != nil {
+		writeError(req.w, http.StatusBadRequest, fmt.Sprintf("Failed parsing json: %s.", err.Error()))
+		return
+	}
+
+	name, _ := action.Context["program"].(string)
+	roundID, _ := action.Context["round_id"].(string)
+	channelID, _ := action.Context["channel_id"].(string)
+	status, _ := action.Context["answer"].(string)
+	rating := 0
+
+	if status == "rate" {
+		option, _ := action.Context["selected_option"].(string)
+		rating, _ = strconv.Atoi(option)
+		status = feedbackMet
+	}
+
+	msg := "Thanks, your answer was saved."
+
+	if (status != feedbackMet && status != feedbackNotMet) || rating < 0 || rating > 5 {
+		msg = "Invalid answer."
+	} else if err := p.saveFeedback(name, roundID, channelID, req.userID, status, rating); err == errMeetingNotFound || err == errProgramNotFound {
+		msg = "The meeting doesn't exist anymore."
+	} else if err == errNotParticipant {
+		msg = "Only the participants of the meeting can answer."
+	} else if err != nil {
+		msg = "Failed to save your answer, contact your administrator."
+	}
+
+	writeJSON(req.w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: msg})
+}
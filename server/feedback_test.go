@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeetingFeedback(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	p.setConfiguration(&configuration{FollowUpDays: "3", RepairUnmet: true})
+
+	answer := func(userID string, context map[string]interface{}) string {
+		body, _ := json.Marshal(model.PostActionIntegrationRequest{UserId: userID, Context: context})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/actions/feedback", strings.NewReader(string(body)))
+		r.Header.Set("Mattermost-User-Id", userID)
+		p.ServeHTTP(nil, w, r)
+
+		response := &model.PostActionIntegrationResponse{}
+		assert.Nil(json.Unmarshal(w.Body.Bytes(), response))
+		return response.EphemeralText
+	}
+
+	p.addUser(defaultProgram, "alice")
+	p.addUser(defaultProgram, "bob")
+	p.runMeetings(defaultProgram, "r1", time.Now(), "")
+
+	program, _ := p.getProgram(defaultProgram)
+	record := program.History[0]
+	assert.Equal(record.Timestamp+3*24*60*60*1000, record.FollowUpAt)
+
+	// the follow-up isn't due yet
+	p.sendFollowUps()
+	program, _ = p.getProgram(defaultProgram)
+	assert.Empty(program.History[0].FollowUpPostID)
+
+	p.updateProgram(defaultProgram, func(program *Program) error {
+		program.History[0].FollowUpAt = model.GetMillis() - 1
+		return nil
+	})
+
+	// a round running meanwhile doesn't hold back the follow-ups
+	_, ok, _ := acquireLease(p.API, roundLeaseKey+defaultProgram, time.Minute)
+	assert.True(ok)
+	p.sendFollowUps()
+	program, _ = p.getProgram(defaultProgram)
+	assert.Equal("post", program.History[0].FollowUpPostID)
+
+	context := func(answer string, option string) map[string]interface{} {
+		return map[string]interface{}{"program": defaultProgram, "round_id": "r1", "channel_id": "channel", "answer": answer, "selected_option": option}
+	}
+
+	assert.Equal("Only the participants of the meeting can answer.", answer("carol", context(feedbackMet, "")))
+	assert.Equal("The meeting doesn't exist anymore.", answer("alice", map[string]interface{}{"program": defaultProgram, "round_id": "r0", "channel_id": "channel", "answer": feedbackMet}))
+	assert.Equal("Thanks, your answer was saved.", answer("alice", context(feedbackNotMet, "")))
+
+	program, _ = p.getProgram(defaultProgram)
+	assert.True(program.History[0].notHappened())
+	assert.Empty(p.matchingHistory(program.History))
+
+	answer("bob", context("rate", "4"))
+	program, _ = p.getProgram(defaultProgram)
+	assert.Equal(&MeetingFeedback{Status: feedbackMet, Rating: 4, Timestamp: program.History[0].Feedback["bob"].Timestamp}, program.History[0].Feedback["bob"])
+	assert.False(program.History[0].notHappened())
+	assert.Len(p.matchingHistory(program.History), 1)
+}
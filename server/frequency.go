@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+)
+
+// Meeting frequencies a user can choose, the users without one meet every round of the program
+const (
+	frequencyAlways   = "always"
+	frequencyWeekly   = "weekly"
+	frequencyBiweekly = "biweekly"
+	frequencyMonthly  = "monthly"
+)
+
+var frequencies = []string{frequencyAlways, frequencyWeekly, frequencyBiweekly, frequencyMonthly}
+
+// frequencySlack lets a round that runs a bit earlier than the previous one still count as due
+const frequencySlack = 12 * time.Hour
+
+var errInvalidFrequency = errors.New("invalid frequency")
+
+// PairedRound is the last round a user met someone in
+type PairedRound struct {
+	RoundID   string `json:"roundId"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// isDue returns true if the frequency of the user lets them meet someone at the time
+func (program *Program) isDue(userID string, at time.Time) bool {
+	last, ok := program.LastPaired[userID]
+	if !ok {
+		return true
+	}
+
+	lastAt := time.Unix(0, last.Timestamp*int64(time.Millisecond))
+
+	var next time.Time
+	switch program.Frequencies[userID] {
+	case frequencyWeekly:
+		next = lastAt.AddDate(0, 0, 7)
+	case frequencyBiweekly:
+		next = lastAt.AddDate(0, 0, 14)
+	case frequencyMonthly:
+		next = lastAt.AddDate(0, 1, 0)
+	default:
+		return true
+	}
+
+	return !at.Before(next.Add(-frequencySlack))
+}
+
+// DueUsers returns the users of the program whose frequency lets them meet someone at the time
+func (program *Program) DueUsers(at time.Time) []string {
+	users := []string{}
+
+	for _, userID := range program.Users {
+		if program.isDue(userID, at) {
+			users = append(users, userID)
+		}
+	}
+
+	return users
+}
+
+// setFrequency saves the meeting frequency of a user of the program
+func (p *Plugin) setFrequency(name string, userID string, frequency string) error {
+	if !utils.Contains(frequencies, frequency) {
+		return errInvalidFrequency
+	}
+
+	return p.updateProgram(name, func(program *Program) error {
+		if program.Frequencies == nil {
+			program.Frequencies = make(map[string]string)
+		}
+
+		if frequency == frequencyAlways {
+			delete(program.Frequencies, userID)
+		} else {
+			program.Frequencies[userID] = frequency
+		}
+		return nil
+	})
+}
+
+// executeFrequencyCommand shows or sets the meeting frequency of the user
+func (p *Plugin) executeFrequencyCommand(program *Program, userID string, params []string) string {
+	if len(params) == 0 {
+		frequency, ok := program.Frequencies[userID]
+		if !ok {
+			frequency = frequencyAlways
+		}
+
+		return fmt.Sprintf("Your meeting frequency is %s. Change it with `/gather-plugin frequency weekly|biweekly|monthly|always`.", frequency)
+	}
+
+	err := p.setFrequency(program.Name, userID, params[0])
+	if err == errInvalidFrequency {
+		return "The frequency must be weekly, biweekly, monthly or always."
+	}
+
+	if err != nil {
+		return "Failed to save your frequency, contact your administrator."
+	}
+
+	if params[0] == frequencyAlways {
+		return "You will meet someone every round."
+	}
+
+	return fmt.Sprintf("You will meet someone %s.", params[0])
+}
+
+// apiSetFrequency sets the meeting frequency of a participant
+func (p *Plugin) apiSetFrequency(req *apiRequest, program *Program, userID string) {
+	if !p.requireSelfOrAdmin(req, userID) {
+		return
+	}
+
+	if !utils.Contains(program.Users, userID) {
+		writeError(req.w, http.StatusNotFound, fmt.Sprintf("The user %s is not a participant of the program %s.", userID, program.Name))
+		return
+	}
+
+	body := struct {
+		Frequency string `json:"frequency"`
+	}{}
+
+	if err := json.NewDecoder(req.r.Body).Decode(&body); err != nil || !utils.Contains(frequencies, body.Frequency) {
+		writeError(req.w, http.StatusBadRequest, "The body must be {\"frequency\": \"weekly\"}, the frequency is weekly, biweekly, monthly or always.")
+		return
+	}
+
+	if err := p.setFrequency(program.Name, userID, body.Frequency); err != nil {
+		writeError(req.w, http.StatusInternalServerError, "Failed to save the frequency.")
+		return
+	}
+
+	program, _ = p.getProgram(program.Name)
+	writeJSON(req.w, http.StatusOK, newParticipantResponse(program, userID))
+}
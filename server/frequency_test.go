@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgramDueUsers(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2020, time.July, 15, 9, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) *PairedRound {
+		return &PairedRound{Timestamp: timeToMillis(now.AddDate(0, 0, -days))}
+	}
+
+	program := NewProgram(defaultProgram)
+	program.Users = []string{"always", "weekly", "biweekly", "monthly", "new"}
+	program.Frequencies = map[string]string{"weekly": frequencyWeekly, "biweekly": frequencyBiweekly, "monthly": frequencyMonthly, "new": frequencyMonthly}
+	program.LastPaired = map[string]*PairedRound{"always": daysAgo(1), "weekly": daysAgo(7), "biweekly": daysAgo(7), "monthly": daysAgo(14)}
+
+	assert.Equal([]string{"always", "weekly", "new"}, program.DueUsers(now))
+
+	// a round that runs a bit earlier than the previous one is still due
+	program.LastPaired["biweekly"] = &PairedRound{Timestamp: timeToMillis(now.AddDate(0, 0, -14).Add(time.Hour))}
+	program.LastPaired["monthly"] = daysAgo(30)
+	assert.Equal([]string{"always", "weekly", "biweekly", "monthly", "new"}, program.DueUsers(now))
+}
+
+func TestFrequencyCommand(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	run := func(userID string, command string) string {
+		response, err := p.ExecuteCommand(nil, &model.CommandArgs{UserId: userID, Command: command})
+		assert.Nil(err)
+		return response.Text
+	}
+
+	run("alice", "/gather-plugin on")
+	run("bob", "/gather-plugin on")
+	run("carol", "/gather-plugin on")
+
+	assert.Contains(run("alice", "/gather-plugin frequency"), "Your meeting frequency is always.")
+	assert.Equal("The frequency must be weekly, biweekly, monthly or always.", run("alice", "/gather-plugin frequency daily"))
+	assert.Equal("The program daily doesn't exist.", run("alice", "/gather-plugin frequency monthly daily"))
+	assert.Equal("You will meet someone monthly.", run("alice", "/gather-plugin frequency monthly"))
+	assert.Equal(frequencyMonthly, p.state.Get().Programs[defaultProgram].Frequencies["alice"])
+
+	// after meeting someone alice waits a month
+	p.updateProgram(defaultProgram, func(program *Program) error {
+		program.Users = []string{"alice", "bob"}
+		return nil
+	})
+	records := p.runMeetings(defaultProgram, "1", time.Now(), "")
+	assert.Len(records, 1)
+
+	program, _ := p.getProgram(defaultProgram)
+	assert.Equal("1", program.LastPaired["alice"].RoundID)
+	assert.Equal([]string{"bob"}, p.matchInput(program).Users)
+
+	assert.Equal("You will meet someone every round.", run("alice", "/gather-plugin frequency always"))
+	program, _ = p.getProgram(defaultProgram)
+	assert.Equal([]string{"alice", "bob"}, p.matchInput(program).Users)
+}
+
+func TestMatchInputLeavesOutUsersNotDue(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	// everyone already met, c met someone yesterday and meets monthly
+	program := NewProgram(defaultProgram)
+	program.Users = []string{"a", "b", "c"}
+	program.Frequencies = map[string]string{"c": frequencyMonthly}
+	program.LastPaired = map[string]*PairedRound{"c": {Timestamp: timeToMillis(time.Now().AddDate(0, 0, -1))}}
+	program.History = History{
+		{RoundID: "r0", Timestamp: 1, Participants: []string{"a", "c"}},
+		{RoundID: "r0", Timestamp: 1, Participants: []string{"b", "c"}},
+		{RoundID: "r0", Timestamp: 1, Participants: []string{"a", "b"}},
+	}
+
+	input := p.matchInput(program)
+	assert.Equal([]string{"a", "b"}, input.Users)
+	assert.Equal(map[string][]string{"a": {"b"}, "b": {"a"}}, input.Meetings)
+
+	for i := 0; i < 20; i++ {
+		result := p.getMatcher().Match(input)
+		assert.ElementsMatch([]string{"a", "b"}, matchedUsers(result.Groups))
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+)
+
+// legacyRoundID is the round of the meetings migrated from the old meetings list, they have no real date
+const legacyRoundID = "legacy"
+
+// MeetingRecord is a meeting started by the plugin
+type MeetingRecord struct {
+	RoundID      string   `json:"roundId"`
+	Timestamp    int64    `json:"timestamp"`
+	Participants []string `json:"participants"`
+	ChannelID    string   `json:"channelId"`
+	PostID       string   `json:"postId"`
+
+	// FollowUpAt is when the participants are asked whether they met, 0 if they are not asked, and
+	// Feedback their answers
+	FollowUpAt     int64                       `json:"followUpAt,omitempty"`
+	FollowUpPostID string                      `json:"followUpPostId,omitempty"`
+	Feedback       map[string]*MeetingFeedback `json:"feedback,omitempty"`
+}
+
+// History is the list of meetings, from the oldest to the newest
+type History []*MeetingRecord
+
+// Add appends a new meeting to the history
+func (h History) Add(record *MeetingRecord) History {
+	return append(h, record)
+}
+
+// Adjacency returns, for every user, the users they met ordered from the least to the most recently met.
+// Only the given users are taken into account.
+func (h History) Adjacency(users []string) map[string][]string {
+	meetings := make(map[string][]string)
+
+	for _, userID := range users {
+		meetings[userID] = []string{}
+	}
+
+	for _, record := range h {
+		participants := []string{}
+
+		for _, userID := range record.Participants {
+			if utils.Contains(users, userID) {
+				participants = append(participants, userID)
+			}
+		}
+
+		recordMeeting(meetings, participants)
+	}
+
+	return meetings
+}
+
+// LastRounds returns the meetings of the last rounds, the meetings started on sign in belong to the round
+// that was running then
+func (h History) LastRounds(rounds int) History {
+	seen := []string{}
+
+	for i := len(h) - 1; i >= 0; i-- {
+		if !utils.Contains(seen, h[i].RoundID) {
+			if len(seen) == rounds {
+				return h[i+1:]
+			}
+
+			seen = append(seen, h[i].RoundID)
+		}
+	}
+
+	return h
+}
+
+// Since returns the meetings started from the given timestamp. The legacy meetings have no real
+// date, they are kept so the migrated users don't meet their old partners again.
+func (h History) Since(timestamp int64) History {
+	history := History{}
+
+	for _, record := range h {
+		if record.Timestamp >= timestamp || record.RoundID == legacyRoundID {
+			history = append(history, record)
+		}
+	}
+
+	return history
+}
+
+// RoundParticipants returns the users that met in the round
+func (h History) RoundParticipants(roundID string) []string {
+	users := []string{}
+
+	for _, record := range h {
+		if record.RoundID == roundID {
+			users = append(users, record.Participants...)
+		}
+	}
+
+	return users
+}
+
+// HasMeetings returns true if the user met someone
+func (h History) HasMeetings(userID string) bool {
+	for _, record := range h {
+		if utils.Contains(record.Participants, userID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LastMeeting returns the last meeting of two users
+func (h History) LastMeeting(userID string, pairUserID string) (*MeetingRecord, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		if utils.Contains(h[i].Participants, userID) && utils.Contains(h[i].Participants, pairUserID) {
+			return h[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// RemoveUser removes the user from every meeting, meetings with only one participant left are removed
+func (h History) RemoveUser(userID string) History {
+	history := History{}
+
+	for _, record := range h {
+		if utils.Contains(record.Participants, userID) {
+			participants := []string{}
+			for _, participant := range record.Participants {
+				if participant != userID {
+					participants = append(participants, participant)
+				}
+			}
+
+			if len(participants) < 2 {
+				continue
+			}
+
+			copied := *record
+			copied.Participants = participants
+			record = &copied
+		}
+
+		history = append(history, record)
+	}
+
+	return history
+}
+
+// historyFromAdjacency converts the old list of users met by every user into meetings. The lists
+// have no dates so the meetings get increasing fake timestamps that keep their relative order.
+func historyFromAdjacency(meetings map[string][]string) History {
+	type legacyPair struct {
+		users [2]string
+		order int
+	}
+
+	pairs := make(map[[2]string]*legacyPair)
+
+	for userID, userMeetings := range meetings {
+		for i, pairUserID := range userMeetings {
+			if userID == pairUserID {
+				continue
+			}
+
+			key := [2]string{userID, pairUserID}
+			if pairUserID < userID {
+				key = [2]string{pairUserID, userID}
+			}
+
+			pair, ok := pairs[key]
+			if !ok {
+				pair = &legacyPair{users: key}
+				pairs[key] = pair
+			}
+
+			if i > pair.order {
+				pair.order = i
+			}
+		}
+	}
+
+	sorted := []*legacyPair{}
+	for _, pair := range pairs {
+		sorted = append(sorted, pair)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].order != sorted[j].order {
+			return sorted[i].order < sorted[j].order
+		}
+		if sorted[i].users[0] != sorted[j].users[0] {
+			return sorted[i].users[0] < sorted[j].users[0]
+		}
+		return sorted[i].users[1] < sorted[j].users[1]
+	})
+
+	history := History{}
+	for i, pair := range sorted {
+		history = append(history, &MeetingRecord{
+			RoundID:      legacyRoundID,
+			Timestamp:    int64(i),
+			Participants: []string{pair.users[0], pair.users[1]},
+		})
+	}
+
+	return history
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryAdjacency(t *testing.T) {
+	assert := assert.New(t)
+	history := History{}.
+		Add(&MeetingRecord{RoundID: "1", Timestamp: 1, Participants: []string{"a", "b"}}).
+		Add(&MeetingRecord{RoundID: "1", Timestamp: 1, Participants: []string{"c", "d"}}).
+		Add(&MeetingRecord{RoundID: "2", Timestamp: 2, Participants: []string{"a", "c", "e"}}).
+		Add(&MeetingRecord{RoundID: "3", Timestamp: 3, Participants: []string{"a", "b"}})
+
+	meetings := history.Adjacency([]string{"a", "b", "c", "d"})
+
+	assert.Equal([]string{"c", "b"}, meetings["a"])
+	assert.Equal([]string{"a"}, meetings["b"])
+	assert.Equal([]string{"d", "a"}, meetings["c"])
+	assert.NotContains(meetings, "e")
+
+	record, ok := history.LastMeeting("b", "a")
+	assert.True(ok)
+	assert.Equal("3", record.RoundID)
+
+	_, ok = history.LastMeeting("b", "d")
+	assert.False(ok)
+}
+
+func TestHistoryRemoveUser(t *testing.T) {
+	assert := assert.New(t)
+	history := History{
+		{RoundID: "1", Participants: []string{"a", "b"}},
+		{RoundID: "1", Participants: []string{"c", "d", "e"}},
+	}
+
+	history = history.RemoveUser("a")
+	history = history.RemoveUser("e")
+
+	assert.Len(history, 1)
+	assert.Equal([]string{"c", "d"}, history[0].Participants)
+	assert.False(history.HasMeetings("a"))
+}
+
+func TestHistoryFromAdjacency(t *testing.T) {
+	assert := assert.New(t)
+	meetings := map[string][]string{
+		"a": {"b", "c"},
+		"b": {"a"},
+		"c": {"a"},
+	}
+
+	history := historyFromAdjacency(meetings)
+
+	assert.Len(history, 2)
+	assert.Equal(legacyRoundID, history[0].RoundID)
+	assert.Equal(meetings, history.Adjacency([]string{"a", "b", "c"}))
+}
+
+func TestHistoryWindow(t *testing.T) {
+	assert := assert.New(t)
+	history := History{
+		{RoundID: "1", Timestamp: 10, Participants: []string{"a", "b"}},
+		{RoundID: "2", Timestamp: 20, Participants: []string{"a", "c"}},
+		{RoundID: "2", Timestamp: 25, Participants: []string{"b", "d"}},
+		{RoundID: "3", Timestamp: 30, Participants: []string{"a", "d"}},
+	}
+
+	assert.Len(history.LastRounds(1), 1)
+	assert.Len(history.LastRounds(2), 3)
+	assert.Len(history.LastRounds(5), 4)
+	assert.Empty(history.LastRounds(0))
+
+	assert.Len(history.Since(20), 3)
+	assert.Len(history.Since(26), 1)
+	assert.Empty(history.Since(31))
+
+	// a and b can meet again once their meeting is out of the window
+	assert.NotContains(history.LastRounds(2).Adjacency([]string{"a", "b"})["a"], "b")
+
+	// the migrated meetings have fake timestamps, they stay in the days window
+	legacy := append(historyFromAdjacency(map[string][]string{"c": {"d"}, "d": {"c"}}), history...)
+	assert.Len(legacy.Since(26), 2)
+	assert.Equal(legacyRoundID, legacy.Since(26)[0].RoundID)
+
+	p := newTestPlugin(newMemoryKV())
+	p.setConfiguration(&configuration{HistoryWindow: "30", HistoryWindowUnit: "days"})
+	assert.Contains(p.matchingHistory(legacy).Adjacency([]string{"c", "d"})["c"], "d")
+}
+
+func TestMeetingRecordJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	data, err := json.Marshal(&MeetingRecord{RoundID: "r1", Timestamp: 10, Participants: []string{"a", "b"}, ChannelID: "channel", PostID: "post", FollowUpAt: 20})
+	assert.Nil(err)
+	assert.JSONEq(`{"roundId": "r1", "timestamp": 10, "participants": ["a", "b"], "channelId": "channel", "postId": "post", "followUpAt": 20}`, string(data))
+}
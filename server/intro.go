@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"text/template"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// conversationStarters are the questions the intro messages can suggest to break the ice
+var conversationStarters = []string{
+	"What is the best thing that happened to you this week?",
+	"What are you working on right now?",
+	"What was your first job?",
+	"What is a book, film or show you recommend?",
+	"Where would you travel tomorrow if you could?",
+	"What is a skill you would like to learn?",
+	"What is your favourite local food?",
+	"What did you want to be when you were a kid?",
+	"What is the best advice you have received?",
+	"What do you do to disconnect after work?",
+}
+
+// IntroParticipant is a participant of a meeting for the intro message template
+type IntroParticipant struct {
+	Username    string
+	DisplayName string
+	Position    string
+	Timezone    string
+}
+
+// IntroData are the variables of the intro message template
+type IntroData struct {
+	Program      string
+	Participants []IntroParticipant
+
+	// Names are the display names of the participants, e.g. "Alice, Bob and Carol"
+	Names string
+
+	// TimesMet is how many times the participants met before and Round the number of the round
+	TimesMet int
+	Round    int
+
+	Starter string
+}
+
+// parseIntroTemplate parses the intro message and checks it renders with every variable
+func parseIntroTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("intro").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	sample := IntroData{
+		Program: defaultProgram,
+		Participants: []IntroParticipant{
+			{Username: "alice", DisplayName: "Alice", Position: "Designer", Timezone: "Europe/Madrid"},
+			{Username: "bob", DisplayName: "Bob", Position: "Developer", Timezone: "America/New_York"},
+		},
+		Names:    "Alice and Bob",
+		TimesMet: 1,
+		Round:    2,
+		Starter:  conversationStarters[0],
+	}
+
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// validateIntroTemplate returns an error if the intro message is not a valid template
+func validateIntroTemplate(text string) error {
+	_, err := parseIntroTemplate(text)
+	return err
+}
+
+// introData collects the variables of the intro message of a meeting of the round
+func (p *Plugin) introData(program *Program, roundID string, users []string) IntroData {
+	data := IntroData{
+		Program:      program.Name,
+		Participants: []IntroParticipant{},
+		Starter:      conversationStarters[rand.Intn(len(conversationStarters))],
+	}
+
+	names := []string{}
+	for _, userID := range users {
+		participant := IntroParticipant{Username: userID, DisplayName: userID}
+
+		if user, err := p.API.GetUser(userID); err == nil {
+			participant = IntroParticipant{
+				Username:    user.Username,
+				DisplayName: user.GetDisplayName(model.SHOW_FULLNAME),
+				Position:    user.Position,
+				Timezone:    user.GetPreferredTimezone(),
+			}
+		} else {
+			p.API.LogWarn(fmt.Sprintf("Failed to get the user %s for the intro message: %s", userID, err.Error()))
+		}
+
+		data.Participants = append(data.Participants, participant)
+		names = append(names, participant.DisplayName)
+	}
+
+	if len(names) > 1 {
+		data.Names = strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+	} else {
+		data.Names = strings.Join(names, "")
+	}
+
+	rounds := []string{}
+	for _, record := range program.History {
+		if record.RoundID != roundID && !utils.Contains(rounds, record.RoundID) {
+			rounds = append(rounds, record.RoundID)
+		}
+
+		if len(utils.Intersect(users, record.Participants)) == len(users) {
+			data.TimesMet++
+		}
+	}
+	data.Round = len(rounds) + 1
+
+	return data
+}
+
+// introMessage renders the intro message of a meeting, the template was validated when it was
+// saved so it only fails on unexpected data
+func (p *Plugin) introMessage(program *Program, roundID string, users []string) string {
+	text := p.getInitText(program)
+
+	tmpl, err := template.New("intro").Parse(text)
+	if err == nil {
+		var message bytes.Buffer
+		if err = tmpl.Execute(&message, p.introData(program, roundID, users)); err == nil {
+			return message.String()
+		}
+	}
+
+	p.API.LogError(fmt.Sprintf("Failed to render the initial text of the program %s: %s", program.Name, err.Error()))
+
+	return "Let's chat!"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIntroTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(validateIntroTemplate("Let's chat!"))
+	assert.Nil(validateIntroTemplate("Hi {{.Names}}, round {{.Round}}{{range .Participants}} @{{.Username}} ({{.Position}}){{end}}. {{.Starter}}"))
+	assert.NotNil(validateIntroTemplate("Hi {{.Names"))
+	assert.NotNil(validateIntroTemplate("Hi {{.Name}}"))
+
+	p := newTestPlugin(newMemoryKV())
+	program := NewProgram(defaultProgram)
+	program.InitText = "Hi {{.Names}}! Round {{.Round}}, you met {{.TimesMet}} times.{{if .Starter}} Starter included.{{end}}"
+	program.History = History{
+		{RoundID: "r1", Participants: []string{"alice", "bob"}},
+		{RoundID: "r2", Participants: []string{"alice", "carol"}},
+		{RoundID: "r3", Participants: []string{"bob", "alice", "carol"}},
+	}
+
+	assert.Equal("Hi alice and bob! Round 4, you met 2 times. Starter included.", p.introMessage(program, "r4", []string{"alice", "bob"}))
+
+	// a template that fails with the actual data isn't posted
+	program.InitText = "Hi {{index .Participants 2}}"
+	assert.Equal("Let's chat!", p.introMessage(program, "r4", []string{"alice", "bob"}))
+}
+
+func TestOnConfigurationChangeRejectsBrokenTemplates(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	p.setConfiguration(&configuration{InitText: "Hi {{.Names}}"})
+
+	initText := "Hi {{.Nmes}}"
+	p.API.(*plugintest.API).On("LoadPluginConfiguration", mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(0).(*configuration).InitText = initText
+	}).Return(nil)
+
+	assert.NotNil(p.OnConfigurationChange())
+	assert.Equal("Hi {{.Names}}", p.getConfiguration().InitText)
+
+	initText = "Hello {{.Names}}"
+	assert.Nil(p.OnConfigurationChange())
+	assert.Equal("Hello {{.Names}}", p.getConfiguration().InitText)
+	p.removeCron()
+}
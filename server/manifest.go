@@ -71,7 +71,7 @@ const manifestStr = `
         "key": "InitText",
         "display_name": "Initial text",
         "type": "text",
-        "help_text": "",
+        "help_text": "Template of the text sent to the users when it's time to chat. Available variables: {{.Names}}, {{.Participants}} (each with .DisplayName, .Username, .Position and .Timezone), {{.TimesMet}}, {{.Round}}, {{.Starter}} and {{.Program}}. Invalid templates are rejected.",
         "placeholder": "",
         "default": "Let's chat!"
       },
@@ -90,6 +90,198 @@ const manifestStr = `
         "help_text": "If this is activated, any user can type '/gather-plugin info' to see who is currently signed up. Otherwise, only system users can.",
         "placeholder": "",
         "default": false
+      },
+      {
+        "key": "Matching",
+        "display_name": "Matching",
+        "type": "dropdown",
+        "help_text": "Greedy pairs first the users with less meetings. Optimal finds the pairs that minimize the repeated meetings of the whole round, preferring the pairs that met longer ago.",
+        "placeholder": "",
+        "default": "greedy",
+        "options": [
+          {
+            "display_name": "Greedy",
+            "value": "greedy"
+          },
+          {
+            "display_name": "Optimal",
+            "value": "optimal"
+          }
+        ]
+      },
+      {
+        "key": "GroupSize",
+        "display_name": "Group size",
+        "type": "dropdown",
+        "help_text": "How many users meet together. If the users can't be split evenly the rest of users join the existing groups.",
+        "placeholder": "",
+        "default": "2",
+        "options": [
+          {
+            "display_name": "2 (pairs)",
+            "value": "2"
+          },
+          {
+            "display_name": "3",
+            "value": "3"
+          },
+          {
+            "display_name": "4",
+            "value": "4"
+          }
+        ]
+      },
+      {
+        "key": "OddUserPolicy",
+        "display_name": "Odd user policy",
+        "type": "dropdown",
+        "help_text": "What happens to the odd user when the number of users is odd. Skip: the user doesn't meet anyone this round. Trio: the user joins an existing pair. Volunteer: the user meets the volunteer. Only applies to pairs.",
+        "placeholder": "",
+        "default": "skip",
+        "options": [
+          {
+            "display_name": "Skip",
+            "value": "skip"
+          },
+          {
+            "display_name": "Trio",
+            "value": "trio"
+          },
+          {
+            "display_name": "Volunteer",
+            "value": "volunteer"
+          }
+        ]
+      },
+      {
+        "key": "OddUserVolunteer",
+        "display_name": "Volunteer",
+        "type": "text",
+        "help_text": "Username of the volunteer that meets the odd user when the odd user policy is Volunteer. The volunteer doesn't take part in the rest of meetings.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "HistoryWindow",
+        "display_name": "History window",
+        "type": "text",
+        "help_text": "Meetings older than this number of rounds or days don't count when pairing users, so people meet again old colleagues. Leave it empty or 0 to remember every meeting.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "HistoryWindowUnit",
+        "display_name": "History window unit",
+        "type": "dropdown",
+        "help_text": "Unit of the history window.",
+        "placeholder": "",
+        "default": "rounds",
+        "options": [
+          {
+            "display_name": "Rounds",
+            "value": "rounds"
+          },
+          {
+            "display_name": "Days",
+            "value": "days"
+          }
+        ]
+      },
+      {
+        "key": "MixingAttribute",
+        "display_name": "Mix users by",
+        "type": "dropdown",
+        "help_text": "The users from different groups are paired first, so people meet colleagues they don't usually work with. Position uses the position of the profile, Team the teams of the user and Tag the tags set by the admins with /gather-plugin tag.",
+        "placeholder": "",
+        "default": "none",
+        "options": [
+          {
+            "display_name": "None",
+            "value": "none"
+          },
+          {
+            "display_name": "Position",
+            "value": "position"
+          },
+          {
+            "display_name": "Team",
+            "value": "team"
+          },
+          {
+            "display_name": "Tag",
+            "value": "tag"
+          }
+        ]
+      },
+      {
+        "key": "MixingStrength",
+        "display_name": "Mixing strength",
+        "type": "dropdown",
+        "help_text": "Low only breaks ties between equally good partners. Medium prefers partners from other groups over partners met longer ago, but users still meet new people first. High prefers partners from other groups even if they already met. With the greedy matching the users just look first for a partner from another group.",
+        "placeholder": "",
+        "default": "medium",
+        "options": [
+          {
+            "display_name": "Low",
+            "value": "low"
+          },
+          {
+            "display_name": "Medium",
+            "value": "medium"
+          },
+          {
+            "display_name": "High",
+            "value": "high"
+          }
+        ]
+      },
+      {
+        "key": "WorkingHours",
+        "display_name": "Working hours",
+        "type": "text",
+        "help_text": "Working hours of the users in their local time, e.g. 09:00-17:00. The timezone of every user is the one of their Mattermost profile.",
+        "placeholder": "",
+        "default": "09:00-17:00"
+      },
+      {
+        "key": "MinimumOverlap",
+        "display_name": "Minimum overlap",
+        "type": "text",
+        "help_text": "Hours the working hours of the users should overlap. The users are paired first with partners whose working hours overlap that long and the initial message shows the overlapping hours in the local time of every user. Leave it empty or 0 to ignore the timezones.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "ConfirmRounds",
+        "display_name": "Ask before each round",
+        "type": "bool",
+        "help_text": "If this is activated the bot asks every user to join each scheduled round with a direct message, and only the users that join are paired after the confirmation cutoff. The rounds run by the admins don't ask.",
+        "placeholder": "",
+        "default": false
+      },
+      {
+        "key": "ConfirmCutoff",
+        "display_name": "Confirmation cutoff",
+        "type": "text",
+        "help_text": "Hours the users have to join a round when Ask before each round is activated. A round still waiting for confirmations runs when the next one is scheduled.",
+        "placeholder": "",
+        "default": "24"
+      },
+      {
+        "key": "FollowUpDays",
+        "display_name": "Follow-up after",
+        "type": "text",
+        "help_text": "Days after a meeting the bot asks the participants in the meeting channel whether they met and how it went. Leave it empty or 0 to never ask.",
+        "placeholder": "",
+        "default": null
+      },
+      {
+        "key": "RepairUnmet",
+        "display_name": "Pair again users that didn't meet",
+        "type": "bool",
+        "help_text": "If this is activated the meetings that the participants said didn't happen don't count when pairing users, so they can be paired again.",
+        "placeholder": "",
+        "default": false
       }
     ]
   }
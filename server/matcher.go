@@ -0,0 +1,822 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+)
+
+// MatchInput is the state a Matcher needs to propose the meetings of a round
+type MatchInput struct {
+	Users       []string
+	Paused      []string
+	Meetings    map[string][]string
+	OddUserTurn []string
+	GroupSize   int
+	OddPolicy   string
+	Volunteer   string
+
+	// Blocked are the users every user must not meet
+	Blocked map[string][]string
+
+	// UserGroups are the groups of every user, e.g. their teams, and Mixing how strongly the users
+	// of different groups are paired. Mixing is empty if the groups don't matter.
+	UserGroups map[string][]string
+	Mixing     string
+
+	// Offsets are the UTC offsets in minutes of the users with a timezone, WorkingHours the start
+	// and the end of the local working day in minutes and MinOverlap how many minutes the working
+	// hours of a pair should overlap. MinOverlap is 0 if the timezones don't matter.
+	Offsets      map[string]int
+	WorkingHours [2]int
+	MinOverlap   int
+}
+
+// Mixing strengths, low only breaks ties, medium prefers mixed pairs over the pairs that met longer
+// ago and high prefers mixed pairs over the pairs that never met
+const (
+	mixingLow    = "low"
+	mixingMedium = "medium"
+	mixingHigh   = "high"
+)
+
+// isMixed returns true if the users belong to different groups, users without groups are never mixed
+func (input MatchInput) isMixed(userID string, pairUserID string) bool {
+	if input.Mixing == "" {
+		return false
+	}
+
+	userGroups := input.UserGroups[userID]
+	pairGroups := input.UserGroups[pairUserID]
+
+	if len(userGroups) == 0 || len(pairGroups) == 0 {
+		return false
+	}
+
+	for _, group := range userGroups {
+		if utils.Contains(pairGroups, group) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mixedFirst sorts the candidates to meet the user so the ones from other groups go first
+func (input MatchInput) mixedFirst(userID string, candidates []string) {
+	if input.Mixing == "" {
+		return
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return input.isMixed(userID, candidates[i]) && !input.isMixed(userID, candidates[j])
+	})
+}
+
+// mixedUsers returns the users from groups other than the user's
+func (input MatchInput) mixedUsers(userID string, users []string) []string {
+	mixed := []string{}
+
+	for _, pairUserID := range users {
+		if input.isMixed(userID, pairUserID) {
+			mixed = append(mixed, pairUserID)
+		}
+	}
+
+	return mixed
+}
+
+// isBlocked returns true if a block rule keeps the users from meeting
+func (input MatchInput) isBlocked(userID string, pairUserID string) bool {
+	return utils.Contains(input.Blocked[userID], pairUserID)
+}
+
+// canJoin returns true if the user can meet every member of the group
+func (input MatchInput) canJoin(group []string, userID string) bool {
+	for _, member := range group {
+		if member != userID && input.isBlocked(member, userID) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchResult is the proposal of a Matcher, nothing has been sent or persisted yet
+type MatchResult struct {
+	Groups      [][]string
+	OddUser     string
+	OddUserTurn []string
+
+	// Unmatched are the available users left without a group because of the block rules
+	Unmatched []string
+}
+
+// Matcher pairs the users of a round without side effects
+type Matcher interface {
+	Match(input MatchInput) MatchResult
+}
+
+// Odd user policies, what happens to the user whose turn is to be the odd one
+const (
+	oddPolicySkip      = "skip"
+	oddPolicyTrio      = "trio"
+	oddPolicyVolunteer = "volunteer"
+)
+
+// newMatcher returns the Matcher for the matching mode configured by the admin
+func newMatcher(mode string) Matcher {
+	if mode == "optimal" {
+		return &oddUserMatcher{matcher: &optimalMatcher{}}
+	}
+
+	return &oddUserMatcher{matcher: &greedyMatcher{}}
+}
+
+// oddUserMatcher applies the odd user policy to the result of another Matcher
+type oddUserMatcher struct {
+	matcher Matcher
+}
+
+// Match implements Matcher
+func (m *oddUserMatcher) Match(input MatchInput) MatchResult {
+	volunteer := ""
+
+	// the volunteer is not part of the round, only meets the odd user
+	if input.OddPolicy == oddPolicyVolunteer && input.GroupSize <= 2 && input.Volunteer != "" {
+		volunteer = input.Volunteer
+		users := []string{}
+
+		for _, userID := range input.Users {
+			if userID != volunteer {
+				users = append(users, userID)
+			}
+		}
+
+		input.Users = users
+	}
+
+	result := m.matcher.Match(input)
+	result.Unmatched = unmatchedUsers(input, result)
+
+	if result.OddUser == "" {
+		return result
+	}
+
+	if input.OddPolicy == oddPolicyTrio {
+		best := -1
+		bestScore := int64(0)
+
+		for i, group := range result.Groups {
+			if !input.canJoin(group, result.OddUser) {
+				continue
+			}
+
+			score := groupScore(input, group, result.OddUser)
+			if best == -1 || score > bestScore {
+				best = i
+				bestScore = score
+			}
+		}
+
+		if best != -1 {
+			result.Groups[best] = append(result.Groups[best], result.OddUser)
+			result.OddUser = ""
+		}
+	} else if volunteer != "" && !input.isBlocked(result.OddUser, volunteer) {
+		result.Groups = append(result.Groups, []string{result.OddUser, volunteer})
+		result.OddUser = ""
+	}
+
+	return result
+}
+
+// unmatchedUsers returns the available users that are not in any group and are not the odd user
+func unmatchedUsers(input MatchInput, result MatchResult) []string {
+	unmatched := []string{}
+
+	for _, userID := range getAvailableUsers(input, result.OddUser) {
+		matched := false
+
+		for _, group := range result.Groups {
+			if utils.Contains(group, userID) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			unmatched = append(unmatched, userID)
+		}
+	}
+
+	return unmatched
+}
+
+// greedyMatcher pairs first the users with less meetings, trying to avoid users they already met
+type greedyMatcher struct{}
+
+// greedyRound holds the working state of a single greedy round
+type greedyRound struct {
+	input      MatchInput
+	meetings   map[string][]string
+	meetInCron []string
+	oddUser    string
+	groups     [][]string
+}
+
+func newGreedyRound(input MatchInput) *greedyRound {
+	meetings := make(map[string][]string)
+
+	for userID, userMeetings := range input.Meetings {
+		meetings[userID] = append([]string{}, userMeetings...)
+	}
+
+	return &greedyRound{
+		input:      input,
+		meetings:   meetings,
+		meetInCron: []string{},
+	}
+}
+
+// Match implements Matcher
+func (m *greedyMatcher) Match(input MatchInput) MatchResult {
+	if input.GroupSize > 2 {
+		return matchGroups(input, false)
+	}
+
+	r := newGreedyRound(input)
+
+	var oddUserTurn []string
+	r.oddUser, oddUserTurn = chooseOddUser(input)
+
+	availableUsers := r.getAvailableUsers()
+
+	utils.ShuffleUsers(availableUsers)
+
+	sort.SliceStable(availableUsers, func(i, j int) bool {
+		return len(r.meetings[availableUsers[i]]) < len(r.meetings[availableUsers[j]])
+	})
+
+	usersWithoutPendingMeetings := []string{}
+	usersWithPendingMeetings := []string{}
+
+	for _, userID := range availableUsers {
+		if r.hasRemeaningMeetings(userID) {
+			userToMeet, ok := r.findUserToMeet(userID)
+
+			if ok {
+				r.pair(userID, userToMeet)
+			} else {
+				usersWithPendingMeetings = append(usersWithPendingMeetings, userID)
+			}
+		} else {
+			usersWithoutPendingMeetings = append(usersWithoutPendingMeetings, userID)
+		}
+	}
+
+	for _, userID := range usersWithPendingMeetings {
+		userToMeet, ok := r.findAnyUserToMeet(userID)
+
+		if ok {
+			r.pair(userID, userToMeet)
+		}
+	}
+
+	for _, userID := range usersWithoutPendingMeetings {
+		userToMeet, ok := r.findUserToMeet(userID)
+
+		if ok {
+			r.pair(userID, userToMeet)
+		}
+	}
+
+	for _, userID := range usersWithoutPendingMeetings {
+		userToMeet, ok := r.findAnyUserToMeet(userID)
+
+		if ok {
+			r.pair(userID, userToMeet)
+		}
+	}
+
+	return MatchResult{
+		Groups:      r.groups,
+		OddUser:     r.oddUser,
+		OddUserTurn: oddUserTurn,
+	}
+}
+
+// findFirstMeeting looks for a partner for a user that just signed in, busy users are already meeting someone
+func findFirstMeeting(input MatchInput, userID string, busy []string) (string, bool) {
+	r := newGreedyRound(input)
+	r.meetInCron = append(r.meetInCron, busy...)
+
+	return r.findUserToMeet(userID)
+}
+
+// optimalMatcher pairs the users with a maximum-weight perfect matching, it maximizes the number of
+// pairs that never met and then how long ago the rest of the pairs met
+type optimalMatcher struct{}
+
+// Match implements Matcher
+func (m *optimalMatcher) Match(input MatchInput) MatchResult {
+	if input.GroupSize > 2 {
+		return matchGroups(input, true)
+	}
+
+	oddUser, oddUserTurn := chooseOddUser(input)
+	availableUsers := getAvailableUsers(input, oddUser)
+
+	// the matching is deterministic, shuffle to break ties randomly
+	utils.ShuffleUsers(availableUsers)
+
+	edges := []weightedEdge{}
+	preferences := map[string][]int64{}
+	maxMetWeight := int64(0)
+
+	for i := range availableUsers {
+		for j := i + 1; j < len(availableUsers); j++ {
+			userID, pairUserID := availableUsers[i], availableUsers[j]
+			if input.isBlocked(userID, pairUserID) {
+				continue
+			}
+
+			weight := metWeight(input.Meetings, userID, pairUserID)
+			if weight > maxMetWeight {
+				maxMetWeight = weight
+			}
+
+			newPair, mixed, overlap := int64(0), int64(0), int64(0)
+			if weight == 0 {
+				newPair = 1
+			}
+			if input.isMixed(userID, pairUserID) {
+				mixed = 1
+			}
+			if input.overlaps(userID, pairUserID) {
+				overlap = 1
+			}
+
+			preferences["newPair"] = append(preferences["newPair"], newPair)
+			preferences["mixed"] = append(preferences["mixed"], mixed)
+			preferences["overlap"] = append(preferences["overlap"], overlap)
+			preferences["metWeight"] = append(preferences["metWeight"], weight)
+			edges = append(edges, weightedEdge{I: i, J: j})
+		}
+	}
+
+	// the preferences from the most to the least important, each one must outweigh any combination
+	// of the following ones, e.g. a pair that never met outweighs any combination of repeated pairs
+	order := []string{"newPair", "metWeight"}
+	switch input.Mixing {
+	case mixingLow:
+		order = []string{"newPair", "metWeight", "mixed"}
+	case mixingMedium:
+		order = []string{"newPair", "mixed", "metWeight"}
+	case mixingHigh:
+		order = []string{"mixed", "newPair", "metWeight"}
+	}
+
+	// users that can't find a time to meet don't meet at all, so the timezones come first
+	if input.MinOverlap > 0 {
+		order = append([]string{"overlap"}, order...)
+	}
+
+	unit := int64(1)
+	for i := len(order) - 1; i >= 0; i-- {
+		maxValue := int64(1)
+		if order[i] == "metWeight" {
+			maxValue = maxMetWeight
+		}
+
+		for k := range edges {
+			edges[k].Weight += preferences[order[i]][k] * unit
+		}
+
+		unit *= maxValue*int64(len(availableUsers)) + 1
+	}
+
+	groups := [][]string{}
+	mate := maxWeightMatching(edges, true)
+
+	for i, j := range mate {
+		if j > i {
+			groups = append(groups, []string{availableUsers[i], availableUsers[j]})
+		}
+	}
+
+	return MatchResult{
+		Groups:      groups,
+		OddUser:     oddUser,
+		OddUserTurn: oddUserTurn,
+	}
+}
+
+// metWeight is 0 if the users never met, otherwise it grows with the number of meetings
+// they had since they met, so the least recently met pairs are preferred
+func metWeight(meetings map[string][]string, userID string, pairUserID string) int64 {
+	userMeetings := meetings[userID]
+	pairMeetings := meetings[pairUserID]
+	userIndex := indexOfUser(userMeetings, pairUserID)
+	pairIndex := indexOfUser(pairMeetings, userID)
+
+	if userIndex == -1 && pairIndex == -1 {
+		return 0
+	}
+
+	return 1 + meetingsSince(userMeetings, userIndex) + meetingsSince(pairMeetings, pairIndex)
+}
+
+func meetingsSince(meetings []string, index int) int64 {
+	if index == -1 {
+		return int64(len(meetings))
+	}
+
+	return int64(len(meetings) - 1 - index)
+}
+
+func indexOfUser(users []string, userID string) int {
+	for i, id := range users {
+		if id == userID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// chooseOddUser picks who sits out the round when the number of available users is odd,
+// it returns the updated odd turn
+func chooseOddUser(input MatchInput) (string, []string) {
+	oddUserTurn := append([]string{}, input.OddUserTurn...)
+	availableUsers := getAvailableUsers(input, "")
+
+	if len(availableUsers)%2 == 0 {
+		return "", oddUserTurn
+	}
+
+	oddUserTurn = fillOddUserTurnList(oddUserTurn, input.Users)
+	oddUser := getOddUser(oddUserTurn, input.Paused, availableUsers)
+	oddUserTurn = utils.Remove(oddUserTurn, oddUser)
+	oddUserTurn = append(oddUserTurn, oddUser)
+
+	return oddUser, oddUserTurn
+}
+
+func getAvailableUsers(input MatchInput, oddUser string) []string {
+	var users []string
+
+	for _, userID := range input.Users {
+		if !utils.Contains(input.Paused, userID) && userID != oddUser {
+			users = append(users, userID)
+		}
+	}
+
+	return users
+}
+
+func fillOddUserTurnList(oddUserTurn []string, users []string) []string {
+	for _, userID := range users {
+		if !utils.Contains(oddUserTurn, userID) {
+			oddUserTurn = append(oddUserTurn, userID)
+		}
+	}
+
+	return oddUserTurn
+}
+
+func getOddUser(oddUserTurn []string, paused []string, availableUsers []string) string {
+	for _, userID := range oddUserTurn {
+		if !utils.Contains(paused, userID) && utils.Contains(availableUsers, userID) {
+			return userID
+		}
+	}
+
+	return oddUserTurn[0]
+}
+
+func (r *greedyRound) getAvailableUsers() []string {
+	return getAvailableUsers(r.input, r.oddUser)
+}
+
+func (r *greedyRound) hasRemeaningMeetings(userID string) bool {
+	availableUsersSize := len(r.getAvailableUsers())
+
+	return len(r.meetings[userID]) < (availableUsersSize - 1)
+}
+
+func (r *greedyRound) isUserInTheCurrentCron(userID string) bool {
+	return utils.Contains(r.meetInCron, userID)
+}
+
+func (r *greedyRound) findUserToMeet(userID string) (string, bool) {
+	if r.isUserInTheCurrentCron(userID) {
+		return "", false
+	}
+
+	availableUsers := r.getAvailableUsers()
+	userMeetings := r.meetings[userID]
+
+	utils.ShuffleUsers(availableUsers)
+	r.input.mixedFirst(userID, availableUsers)
+
+	// the users with overlapping working hours go first, even if the user already met them
+	if overlapping := r.input.overlappingUsers(userID, availableUsers); len(overlapping) < len(availableUsers) {
+		if pairUserID, ok := r.getUserNeverMet(userID, overlapping); ok {
+			return pairUserID, true
+		}
+
+		if pairUserID, ok := r.getUserWithoutMeeting(userID, r.input.overlappingUsers(userID, userMeetings)); ok {
+			return pairUserID, true
+		}
+	}
+
+	// with high mixing the users from other groups go first, even if the user already met them
+	if r.input.Mixing == mixingHigh {
+		if pairUserID, ok := r.getUserNeverMet(userID, r.input.mixedUsers(userID, availableUsers)); ok {
+			return pairUserID, true
+		}
+
+		if pairUserID, ok := r.getUserWithoutMeeting(userID, r.input.mixedUsers(userID, userMeetings)); ok {
+			return pairUserID, true
+		}
+	}
+
+	// find if the user haven't meet someone
+	if pairUserID, ok := r.getUserNeverMet(userID, availableUsers); ok {
+		return pairUserID, true
+	}
+
+	// with medium mixing the users from other groups go first among the previous meetings
+	if r.input.Mixing == mixingMedium {
+		userMeetings = append([]string{}, userMeetings...)
+		r.input.mixedFirst(userID, userMeetings)
+	}
+
+	// get user from previous meetings
+	return r.getUserWithoutMeeting(userID, userMeetings)
+}
+
+func (r *greedyRound) getUserNeverMet(userID string, users []string) (string, bool) {
+	for _, pairUserID := range users {
+		if pairUserID != userID &&
+			!r.isUserInTheCurrentCron(pairUserID) &&
+			!r.input.isBlocked(userID, pairUserID) &&
+			!utils.Contains(r.meetings[userID], pairUserID) {
+			return pairUserID, true
+		}
+	}
+
+	return "", false
+}
+
+func (r *greedyRound) getUserWithoutMeeting(userID string, users []string) (string, bool) {
+	availableUsers := r.getAvailableUsers()
+
+	for _, pairUserID := range users {
+		// the previous meetings include users out of the round, e.g. the odd user, who sits out the
+		// round or joins a group after it, or the users that skip the round
+		if !utils.Contains(availableUsers, pairUserID) {
+			continue
+		}
+
+		if !r.isUserInTheCurrentCron(pairUserID) && !r.input.isBlocked(userID, pairUserID) {
+			return pairUserID, true
+		}
+	}
+
+	return "", false
+}
+
+func (r *greedyRound) findAnyUserToMeet(userID string) (string, bool) {
+	if r.isUserInTheCurrentCron(userID) {
+		return "", false
+	}
+
+	availableUsers := r.getAvailableUsers()
+	utils.ShuffleUsers(availableUsers)
+	r.input.mixedFirst(userID, availableUsers)
+	r.input.overlappingFirst(userID, availableUsers)
+
+	for _, pairUserID := range availableUsers {
+		if pairUserID != userID && !r.isUserInTheCurrentCron(pairUserID) && !r.input.isBlocked(userID, pairUserID) {
+			return pairUserID, true
+		}
+	}
+
+	return "", false
+}
+
+func (r *greedyRound) pair(userID string, pairUserID string) {
+	recordMeeting(r.meetings, []string{userID, pairUserID})
+
+	r.meetInCron = append(r.meetInCron, userID, pairUserID)
+	r.groups = append(r.groups, []string{userID, pairUserID})
+}
+
+// recordMeeting moves every member of the group to the end of the meetings of the rest of members
+func recordMeeting(meetings map[string][]string, users []string) {
+	for _, userID := range users {
+		for _, pairUserID := range users {
+			if userID != pairUserID {
+				newUserMeetings := utils.Remove(meetings[userID], pairUserID)
+				meetings[userID] = append(newUserMeetings, pairUserID)
+			}
+		}
+	}
+}
+
+// matchGroups splits the available users in groups of input.GroupSize, the leftovers join the
+// existing groups and the sizes of the groups differ by at most one. Each user joins the group where
+// they met less people or met them longer ago, if improve is true the groups are refined swapping
+// members between them.
+func matchGroups(input MatchInput, improve bool) MatchResult {
+	availableUsers := getAvailableUsers(input, "")
+	size := input.GroupSize
+	groups := [][]string{}
+
+	if len(availableUsers) < 2 {
+		return MatchResult{Groups: groups, OddUserTurn: input.OddUserTurn}
+	}
+
+	utils.ShuffleUsers(availableUsers)
+
+	// the blocked users seed the groups, they are the hardest to place
+	sort.SliceStable(availableUsers, func(i, j int) bool {
+		blockedI, blockedJ := len(input.Blocked[availableUsers[i]]), len(input.Blocked[availableUsers[j]])
+		if blockedI != blockedJ {
+			return blockedI > blockedJ
+		}
+
+		return len(input.Meetings[availableUsers[i]]) < len(input.Meetings[availableUsers[j]])
+	})
+
+	// the leftovers join the groups one by one, if there are too many they make another group and
+	// the users are spread over the groups so they are balanced
+	groupsCount := len(availableUsers) / size
+	if len(availableUsers)%size > groupsCount {
+		groupsCount++
+	}
+
+	pending := availableUsers
+
+	for i := 0; i < groupsCount && len(pending) > 0; i++ {
+		groupSize := len(availableUsers) / groupsCount
+		if i < len(availableUsers)%groupsCount {
+			groupSize++
+		}
+
+		group := []string{pending[0]}
+		pending = pending[1:]
+
+		for len(group) < groupSize && len(pending) > 0 {
+			best := bestCandidate(input, group, pending)
+			if best == -1 {
+				break
+			}
+
+			group = append(group, pending[best])
+			pending = append(pending[:best:best], pending[best+1:]...)
+		}
+
+		groups = append(groups, group)
+	}
+
+	for _, userID := range pending {
+		bestGroup := -1
+		bestScore := int64(0)
+
+		for i, group := range groups {
+			if len(group) > size || !input.canJoin(group, userID) {
+				continue
+			}
+
+			score := groupScore(input, group, userID)
+			if bestGroup == -1 || score > bestScore {
+				bestGroup = i
+				bestScore = score
+			}
+		}
+
+		// the block rules leave the user without a group
+		if bestGroup != -1 {
+			groups[bestGroup] = append(groups[bestGroup], userID)
+		}
+	}
+
+	if improve {
+		improveGroups(input, groups)
+	}
+
+	// a user alone because of the block rules doesn't meet anyone
+	matched := [][]string{}
+	for _, group := range groups {
+		if len(group) > 1 {
+			matched = append(matched, group)
+		}
+	}
+
+	return MatchResult{
+		Groups:      matched,
+		OddUserTurn: input.OddUserTurn,
+	}
+}
+
+// pairScore is how much a pair is worth meeting, pairs that never met are worth the most unless
+// the users are mixed with high strength
+func pairScore(input MatchInput, userID string, pairUserID string) int64 {
+	weight := metWeight(input.Meetings, userID, pairUserID)
+
+	if weight == 0 {
+		weight = newPairScore
+	}
+
+	if input.isMixed(userID, pairUserID) {
+		weight += mixingScores[input.Mixing]
+	}
+
+	if input.MinOverlap > 0 && input.overlaps(userID, pairUserID) {
+		weight += overlapScore
+	}
+
+	return weight
+}
+
+// newPairScore is bigger than any metWeight of a realistic history
+const newPairScore = int64(1) << 40
+
+// overlapScore is added to the score of the pairs whose working hours overlap, it's bigger than any
+// other score
+const overlapScore = int64(1) << 43
+
+// mixingScores are added to the score of the pairs of users from different groups, medium is bigger
+// than any metWeight and high bigger than newPairScore
+var mixingScores = map[string]int64{
+	mixingLow:    1,
+	mixingMedium: int64(1) << 30,
+	mixingHigh:   int64(1) << 41,
+}
+
+func groupScore(input MatchInput, group []string, userID string) int64 {
+	score := int64(0)
+
+	for _, member := range group {
+		if member != userID {
+			score += pairScore(input, member, userID)
+		}
+	}
+
+	return score
+}
+
+// bestCandidate returns the candidate that adds the most to the group, -1 if the block rules leave none
+func bestCandidate(input MatchInput, group []string, candidates []string) int {
+	best := -1
+	bestScore := int64(0)
+
+	for i, userID := range candidates {
+		if !input.canJoin(group, userID) {
+			continue
+		}
+
+		score := groupScore(input, group, userID)
+		if best == -1 || score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+// improveGroups swaps members between groups while the swap increases the score of both groups together,
+// swaps that break a block rule are not made
+func improveGroups(input MatchInput, groups [][]string) {
+	for pass := 0; pass < 10; pass++ {
+		improved := false
+
+		for a := range groups {
+			for b := a + 1; b < len(groups); b++ {
+				for i, userA := range groups[a] {
+					for j, userB := range groups[b] {
+						before := groupScore(input, groups[a], userA) + groupScore(input, groups[b], userB)
+
+						groups[a][i], groups[b][j] = userB, userA
+						after := groupScore(input, groups[a], userB) + groupScore(input, groups[b], userA)
+
+						if after > before && input.canJoin(groups[a], userB) && input.canJoin(groups[b], userA) {
+							improved = true
+							userA = userB
+						} else {
+							groups[a][i], groups[b][j] = userA, userB
+						}
+					}
+				}
+			}
+		}
+
+		if !improved {
+			return
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func matchedUsers(groups [][]string) []string {
+	users := []string{}
+
+	for _, group := range groups {
+		users = append(users, group...)
+	}
+
+	return users
+}
+
+func groupSizes(groups [][]string) []int {
+	sizes := []int{}
+
+	for _, group := range groups {
+		sizes = append(sizes, len(group))
+	}
+
+	return sizes
+}
+
+func TestGreedyMatcherPairsEveryone(t *testing.T) {
+	assert := assert.New(t)
+	matcher := &greedyMatcher{}
+
+	result := matcher.Match(MatchInput{
+		Users:    []string{"a", "b", "c", "d", "e", "f"},
+		Meetings: map[string][]string{},
+	})
+
+	assert.Len(result.Groups, 3)
+	assert.ElementsMatch([]string{"a", "b", "c", "d", "e", "f"}, matchedUsers(result.Groups))
+	assert.Equal("", result.OddUser)
+}
+
+func TestGreedyMatcherSkipsPausedAndOddUser(t *testing.T) {
+	assert := assert.New(t)
+	matcher := &greedyMatcher{}
+
+	result := matcher.Match(MatchInput{
+		Users:       []string{"a", "b", "c", "d", "e", "f"},
+		Paused:      []string{"f"},
+		Meetings:    map[string][]string{},
+		OddUserTurn: []string{"f", "c", "a"},
+	})
+
+	// f is paused so c is the next one in the odd turn
+	assert.Equal("c", result.OddUser)
+	assert.Equal([]string{"f", "a", "b", "d", "e", "c"}, result.OddUserTurn)
+	assert.Len(result.Groups, 2)
+	assert.ElementsMatch([]string{"a", "b", "d", "e"}, matchedUsers(result.Groups))
+}
+
+func TestGreedyMatcherAvoidsPreviousMeetings(t *testing.T) {
+	assert := assert.New(t)
+	matcher := &greedyMatcher{}
+	meetings := map[string][]string{
+		"a": {"b"},
+		"b": {"a"},
+		"c": {"d"},
+		"d": {"c"},
+	}
+
+	for i := 0; i < 20; i++ {
+		result := matcher.Match(MatchInput{
+			Users:    []string{"a", "b", "c", "d"},
+			Meetings: meetings,
+		})
+
+		assert.Len(result.Groups, 2)
+
+		for _, pair := range result.Groups {
+			assert.NotContains(meetings[pair[0]], pair[1])
+		}
+	}
+
+	// the input is not modified
+	assert.Equal([]string{"b"}, meetings["a"])
+}
+
+func TestOptimalMatcherAvoidsRepeatedMeetings(t *testing.T) {
+	assert := assert.New(t)
+	matcher := &optimalMatcher{}
+
+	// the previous meetings form a cycle a-b-c-d-e-f-a, a round without repeats exists
+	meetings := map[string][]string{
+		"a": {"b", "f"},
+		"b": {"a", "c"},
+		"c": {"b", "d"},
+		"d": {"c", "e"},
+		"e": {"d", "f"},
+		"f": {"e", "a"},
+	}
+
+	for i := 0; i < 20; i++ {
+		result := matcher.Match(MatchInput{
+			Users:    []string{"a", "b", "c", "d", "e", "f"},
+			Meetings: meetings,
+		})
+
+		assert.Len(result.Groups, 3)
+		assert.ElementsMatch([]string{"a", "b", "c", "d", "e", "f"}, matchedUsers(result.Groups))
+
+		for _, pair := range result.Groups {
+			assert.NotContains(meetings[pair[0]], pair[1])
+		}
+	}
+}
+
+func TestOptimalMatcherPrefersLeastRecentlyMet(t *testing.T) {
+	assert := assert.New(t)
+	matcher := &optimalMatcher{}
+
+	// everybody met, a-b and c-d met longer ago than the rest
+	meetings := map[string][]string{
+		"a": {"b", "c", "d"},
+		"b": {"a", "d", "c"},
+		"c": {"d", "a", "b"},
+		"d": {"c", "b", "a"},
+	}
+
+	result := matcher.Match(MatchInput{
+		Users:    []string{"a", "b", "c", "d"},
+		Meetings: meetings,
+	})
+
+	assert.Len(result.Groups, 2)
+
+	for _, pair := range result.Groups {
+		assert.Contains([][]string{{"a", "b"}, {"b", "a"}, {"c", "d"}, {"d", "c"}}, pair)
+	}
+}
+
+func TestMatchersFormGroups(t *testing.T) {
+	assert := assert.New(t)
+	users := []string{"a", "b", "c", "d", "e", "f", "g"}
+
+	for _, matcher := range []Matcher{&greedyMatcher{}, &optimalMatcher{}} {
+		result := matcher.Match(MatchInput{
+			Users:     users,
+			Meetings:  map[string][]string{},
+			GroupSize: 3,
+		})
+
+		// nobody sits out, the leftover joins one of the groups
+		assert.Equal("", result.OddUser)
+		assert.Len(result.Groups, 2)
+		assert.ElementsMatch(users, matchedUsers(result.Groups))
+		assert.ElementsMatch([]int{3, 4}, []int{len(result.Groups[0]), len(result.Groups[1])})
+
+		result = matcher.Match(MatchInput{
+			Users:     users,
+			Meetings:  map[string][]string{},
+			GroupSize: 4,
+		})
+
+		// too many leftovers for one group, they make their own group
+		assert.ElementsMatch([]int{4, 3}, []int{len(result.Groups[0]), len(result.Groups[1])})
+	}
+}
+
+func TestMatchersBalanceGroups(t *testing.T) {
+	assert := assert.New(t)
+	users := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"}
+
+	tests := []struct {
+		users int
+		sizes []int
+	}{
+		{5, []int{5}},
+		{6, []int{3, 3}},
+		{7, []int{4, 3}},
+		{9, []int{5, 4}},
+	}
+
+	for _, test := range tests {
+		for _, matcher := range []Matcher{&greedyMatcher{}, &optimalMatcher{}} {
+			result := matcher.Match(MatchInput{
+				Users:     users[:test.users],
+				Meetings:  map[string][]string{},
+				GroupSize: 4,
+			})
+
+			assert.ElementsMatch(users[:test.users], matchedUsers(result.Groups))
+			assert.ElementsMatch(test.sizes, groupSizes(result.Groups), "%d users", test.users)
+		}
+	}
+}
+
+func TestOptimalMatcherGroupsAvoidRepeatedMeetings(t *testing.T) {
+	assert := assert.New(t)
+	matcher := &optimalMatcher{}
+
+	// a-b-c and d-e-f met last round
+	meetings := map[string][]string{}
+	recordMeeting(meetings, []string{"a", "b", "c"})
+	recordMeeting(meetings, []string{"d", "e", "f"})
+
+	for i := 0; i < 20; i++ {
+		result := matcher.Match(MatchInput{
+			Users:     []string{"a", "b", "c", "d", "e", "f"},
+			Meetings:  meetings,
+			GroupSize: 3,
+		})
+
+		assert.Len(result.Groups, 2)
+
+		for _, group := range result.Groups {
+			repeated := 0
+			for _, userID := range group {
+				for _, pairUserID := range group {
+					if userID != pairUserID && utils.Contains(meetings[userID], pairUserID) {
+						repeated++
+					}
+				}
+			}
+
+			// with two groups of three one repeated pair per group is unavoidable
+			assert.LessOrEqual(repeated, 2)
+		}
+	}
+}
+
+func TestRecordMeetingUpdatesEveryPair(t *testing.T) {
+	assert := assert.New(t)
+	meetings := map[string][]string{
+		"a": {"b", "c"},
+	}
+
+	recordMeeting(meetings, []string{"a", "b", "d"})
+
+	assert.Equal([]string{"c", "b", "d"}, meetings["a"])
+	assert.Equal([]string{"a", "d"}, meetings["b"])
+	assert.Equal([]string{"a", "b"}, meetings["d"])
+}
+
+func TestOddUserPolicies(t *testing.T) {
+	assert := assert.New(t)
+
+	// everyone already met, so the greedy matcher falls back to the previous meetings
+	meetings := map[string][]string{}
+	recordMeeting(meetings, []string{"a", "b", "c", "d", "e"})
+
+	input := MatchInput{
+		Users:       []string{"a", "b", "c", "d", "e"},
+		Meetings:    meetings,
+		OddUserTurn: []string{"c"},
+	}
+
+	for _, mode := range []string{"greedy", "optimal"} {
+		matcher := newMatcher(mode)
+
+		for i := 0; i < 20; i++ {
+			input.OddPolicy = oddPolicySkip
+			result := matcher.Match(input)
+			assert.Equal("c", result.OddUser)
+			assert.Len(result.Groups, 2)
+			assert.NotContains(matchedUsers(result.Groups), "c")
+			assert.ElementsMatch([]string{"a", "b", "d", "e"}, matchedUsers(result.Groups), "%s %v", mode, result.Groups)
+
+			input.OddPolicy = oddPolicyTrio
+			result = matcher.Match(input)
+			assert.Equal("", result.OddUser)
+			assert.Len(result.Groups, 2)
+			assert.ElementsMatch(input.Users, matchedUsers(result.Groups), "%s %v", mode, result.Groups)
+			assert.Equal([]string{"a", "b", "d", "e", "c"}, result.OddUserTurn)
+		}
+
+		input.OddPolicy = oddPolicyVolunteer
+		input.Volunteer = "v"
+		result := matcher.Match(input)
+		assert.Equal("", result.OddUser)
+		assert.Len(result.Groups, 3)
+		assert.Contains(result.Groups, []string{"c", "v"})
+
+		// an enrolled volunteer only meets the odd user
+		input.Users = append(input.Users, "v")
+		result = matcher.Match(input)
+		assert.Equal("", result.OddUser)
+		assert.Contains(result.Groups, []string{"c", "v"})
+		input.Users = input.Users[:5]
+	}
+}
+
+func TestMatchersRespectBlocks(t *testing.T) {
+	assert := assert.New(t)
+	blocked := func(pairs ...[2]string) map[string][]string {
+		blocks := map[string][]string{}
+		for _, pair := range pairs {
+			blocks[pair[0]] = append(blocks[pair[0]], pair[1])
+			blocks[pair[1]] = append(blocks[pair[1]], pair[0])
+		}
+		return blocks
+	}
+
+	// a is blocked with everyone but d, the only valid round is a-d and b-c
+	input := MatchInput{
+		Users:    []string{"a", "b", "c", "d"},
+		Meetings: map[string][]string{"a": {"d"}, "d": {"a"}},
+		Blocked:  blocked([2]string{"a", "b"}, [2]string{"a", "c"}),
+	}
+
+	for _, mode := range []string{"greedy", "optimal"} {
+		for i := 0; i < 20; i++ {
+			result := newMatcher(mode).Match(input)
+
+			for _, group := range result.Groups {
+				for _, userID := range group {
+					assert.True(input.canJoin(group, userID), "%s paired blocked users %v", mode, group)
+				}
+			}
+
+			if mode == "optimal" {
+				assert.Len(result.Groups, 2)
+				assert.Empty(result.Unmatched)
+			}
+		}
+	}
+
+	// nobody can meet a, so a is left out
+	input.Blocked = blocked([2]string{"a", "b"}, [2]string{"a", "c"}, [2]string{"a", "d"})
+	input.Users = []string{"a", "b", "c", "d", "e"}
+	input.OddUserTurn = []string{"e"}
+	for _, mode := range []string{"greedy", "optimal"} {
+		result := newMatcher(mode).Match(input)
+		assert.Equal("e", result.OddUser)
+		assert.Contains(result.Unmatched, "a")
+		assert.NotContains(matchedUsers(result.Groups), "a")
+	}
+
+	// the odd user doesn't join a group or meet a volunteer they are blocked with
+	input = MatchInput{
+		Users:       []string{"a", "b", "c"},
+		Meetings:    map[string][]string{},
+		OddUserTurn: []string{"c"},
+		OddPolicy:   oddPolicyTrio,
+		Blocked:     blocked([2]string{"a", "c"}),
+	}
+	result := newMatcher("optimal").Match(input)
+	assert.Equal("c", result.OddUser)
+	assert.Len(result.Groups, 1)
+
+	input.OddPolicy = oddPolicyVolunteer
+	input.Volunteer = "a"
+	input.Users = []string{"b", "c", "d"}
+	result = newMatcher("optimal").Match(input)
+	assert.Equal("c", result.OddUser)
+
+	// groups
+	input = MatchInput{
+		Users:     []string{"a", "b", "c", "d", "e", "f"},
+		Meetings:  map[string][]string{},
+		GroupSize: 3,
+		Blocked:   blocked([2]string{"a", "b"}, [2]string{"a", "c"}),
+	}
+	for _, mode := range []string{"greedy", "optimal"} {
+		result := newMatcher(mode).Match(input)
+		assert.Len(result.Groups, 2)
+
+		for _, group := range result.Groups {
+			for _, userID := range group {
+				assert.True(input.canJoin(group, userID), "%s grouped blocked users %v", mode, group)
+			}
+		}
+	}
+
+	// the first meeting of a new user
+	input = MatchInput{
+		Users:    []string{"a", "b", "c"},
+		Meetings: map[string][]string{},
+		Blocked:  blocked([2]string{"a", "b"}),
+	}
+	userToMeet, ok := findFirstMeeting(input, "a", []string{"c"})
+	assert.False(ok)
+	assert.Empty(userToMeet)
+}
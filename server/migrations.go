@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// schemaVersionKey stores how many migrations have been applied to the stored data
+const schemaVersionKey = "schemaVersion"
+
+// kvAPI is the part of the plugin API the migrations need
+type kvAPI interface {
+	KVGet(key string) ([]byte, *model.AppError)
+	KVSet(key string, value []byte) *model.AppError
+}
+
+// migration upgrades the stored data to the next schema version
+type migration struct {
+	name string
+	run  func(kv kvAPI) error
+}
+
+// migrations are applied in order, never remove or reorder them. The schema version is the number
+// of migrations applied.
+var migrations = []migration{
+	{name: "meetings list to history", run: migrateMeetingsToHistory},
+	{name: "single state value", run: migrateKeysToState},
+	{name: "programs", run: migrateStateToPrograms},
+}
+
+// migrate applies the pending migrations, a failed migration stops the activation so no data is discarded
+func (p *Plugin) migrate() error {
+	applied, err := applyMigrations(p.API, migrations)
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to migrate the stored data: %s", err.Error()))
+		return err
+	}
+
+	for _, m := range applied {
+		p.API.LogInfo(fmt.Sprintf("Applied migration: %s", m.name))
+	}
+
+	return nil
+}
+
+// applyMigrations runs the migrations after the stored schema version, updating the version after each one
+func applyMigrations(kv kvAPI, list []migration) ([]migration, error) {
+	applied := []migration{}
+
+	version, err := getSchemaVersion(kv)
+	if err != nil {
+		return applied, err
+	}
+
+	if version > len(list) {
+		return applied, errors.Errorf("the stored data has schema version %d, this version of the plugin only supports up to %d", version, len(list))
+	}
+
+	for i := version; i < len(list); i++ {
+		if err := list[i].run(kv); err != nil {
+			return applied, errors.Wrapf(err, "migration %d (%s) failed", i+1, list[i].name)
+		}
+
+		if err := setSchemaVersion(kv, i+1); err != nil {
+			return applied, errors.Wrapf(err, "failed to persist schema version %d", i+1)
+		}
+
+		applied = append(applied, list[i])
+	}
+
+	return applied, nil
+}
+
+func getSchemaVersion(kv kvAPI) (int, error) {
+	data, appErr := kv.KVGet(schemaVersionKey)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	if data == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to parse the schema version")
+	}
+
+	return version, nil
+}
+
+func setSchemaVersion(kv kvAPI, version int) error {
+	if appErr := kv.KVSet(schemaVersionKey, []byte(strconv.Itoa(version))); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// loadJSON decodes the value of key into value, it's left untouched if the key doesn't exist
+func loadJSON(kv kvAPI, key string, value interface{}) error {
+	data, appErr := kv.KVGet(key)
+	if appErr != nil {
+		return appErr
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return errors.Wrapf(err, "failed to decode %s", key)
+	}
+
+	return nil
+}
+
+// migrateMeetingsToHistory converts the list of users met by every user into timestamped meetings
+func migrateMeetingsToHistory(kv kvAPI) error {
+	historyData, appErr := kv.KVGet("history")
+	if appErr != nil {
+		return appErr
+	}
+
+	// already migrated by a previous version
+	if historyData != nil {
+		return nil
+	}
+
+	meetings := make(map[string][]string)
+	if err := loadJSON(kv, "meetings", &meetings); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(historyFromAdjacency(meetings))
+	if err != nil {
+		return err
+	}
+
+	if appErr := kv.KVSet("history", data); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// migrateKeysToState merges the users, paused, history and oddUserTurn keys into a single state value
+func migrateKeysToState(kv kvAPI) error {
+	stateData, appErr := kv.KVGet(stateKey)
+	if appErr != nil {
+		return appErr
+	}
+
+	if stateData != nil {
+		return nil
+	}
+
+	// the state had the fields of a program before there were programs
+	state := NewProgram(defaultProgram)
+
+	if err := loadJSON(kv, "users", &state.Users); err != nil {
+		return err
+	}
+
+	if err := loadJSON(kv, "paused", &state.Paused); err != nil {
+		return err
+	}
+
+	if err := loadJSON(kv, "history", &state.History); err != nil {
+		return err
+	}
+
+	if err := loadJSON(kv, "oddUserTurn", &state.OddUserTurn); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if appErr := kv.KVSet(stateKey, data); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// migrateStateToPrograms moves the users, history and rounds of the state into the default program
+func migrateStateToPrograms(kv kvAPI) error {
+	stateData, appErr := kv.KVGet(stateKey)
+	if appErr != nil {
+		return appErr
+	}
+
+	if stateData == nil {
+		return nil
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(stateData, &fields); err != nil {
+		return errors.Wrap(err, "failed to decode the state")
+	}
+
+	if _, ok := fields["programs"]; ok {
+		return nil
+	}
+
+	program := NewProgram(defaultProgram)
+	if err := json.Unmarshal(stateData, program); err != nil {
+		return errors.Wrap(err, "failed to decode the state")
+	}
+
+	program.Name = defaultProgram
+
+	data, err := json.Marshal(&State{Programs: map[string]*Program{defaultProgram: program}})
+	if err != nil {
+		return err
+	}
+
+	if appErr := kv.KVSet(stateKey, data); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
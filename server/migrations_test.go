@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyMigrations(t *testing.T) {
+	assert := assert.New(t)
+	kv := newMemoryKV()
+	kv.KVSet("users", []byte(`["a","b","c"]`))
+	kv.KVSet("paused", []byte(`["c"]`))
+	kv.KVSet("meetings", []byte(`{"a":["b"],"b":["a"]}`))
+
+	applied, err := applyMigrations(kv, migrations)
+	assert.Nil(err)
+	assert.Len(applied, len(migrations))
+	assert.Equal(strconv.Itoa(len(migrations)), string(kv.values[schemaVersionKey]))
+
+	state, err := newMemoryStore(kv).Load()
+	assert.Nil(err)
+	assert.Len(state.Programs, 1)
+
+	program := state.Programs[defaultProgram]
+	assert.Equal(defaultProgram, program.Name)
+	assert.Equal([]string{"a", "b", "c"}, program.Users)
+	assert.Equal([]string{"c"}, program.Paused)
+	assert.Empty(program.OddUserTurn)
+	assert.Len(program.History, 1)
+	assert.ElementsMatch([]string{"a", "b"}, program.History[0].Participants)
+
+	// the legacy data is kept
+	assert.NotNil(kv.values["meetings"])
+
+	// nothing left to apply
+	applied, err = applyMigrations(kv, migrations)
+	assert.Nil(err)
+	assert.Empty(applied)
+}
+
+func TestApplyMigrationsStopsOnFailure(t *testing.T) {
+	assert := assert.New(t)
+	kv := newMemoryKV()
+	list := []migration{
+		{name: "ok", run: func(kv kvAPI) error { return nil }},
+		{name: "broken", run: func(kv kvAPI) error { return errors.New("boom") }},
+		{name: "never", run: func(kv kvAPI) error { return nil }},
+	}
+
+	applied, err := applyMigrations(kv, list)
+	assert.NotNil(err)
+	assert.Len(applied, 1)
+	assert.Equal("1", string(kv.values[schemaVersionKey]))
+
+	// a corrupted legacy value fails the migration instead of being discarded
+	kv = newMemoryKV()
+	kv.KVSet("meetings", []byte("not json"))
+	_, err = applyMigrations(kv, migrations)
+	assert.NotNil(err)
+	assert.Nil(kv.values["history"])
+	assert.Nil(kv.values[schemaVersionKey])
+
+	// data written by a newer version is not touched
+	kv = newMemoryKV()
+	kv.KVSet(schemaVersionKey, []byte("99"))
+	_, err = applyMigrations(kv, migrations)
+	assert.NotNil(err)
+}
+
+func TestMigrateStateToPrograms(t *testing.T) {
+	assert := assert.New(t)
+	kv := newMemoryKV()
+	kv.KVSet(stateKey, []byte(`{"users":["a","b"],"paused":["b"],"history":[],"oddUserTurn":["a"],"lastRoundId":"r1","lastRoundAt":10,"oddUser":"a"}`))
+
+	assert.Nil(migrateStateToPrograms(kv))
+
+	state, err := newMemoryStore(kv).Load()
+	assert.Nil(err)
+	assert.Equal([]string{defaultProgram}, state.ProgramNames())
+
+	program := state.Programs[defaultProgram]
+	assert.Equal([]string{"a", "b"}, program.Users)
+	assert.Equal([]string{"b"}, program.Paused)
+	assert.Equal([]string{"a"}, program.OddUserTurn)
+	assert.Equal("r1", program.LastRoundID)
+	assert.Equal(int64(10), program.LastRoundAt)
+
+	// migrated states are left alone
+	data := kv.values[stateKey]
+	assert.Nil(migrateStateToPrograms(kv))
+	assert.Equal(data, kv.values[stateKey])
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// User attributes that split the users in groups to mix them
+const (
+	mixingByPosition = "position"
+	mixingByTeam     = "team"
+	mixingByTag      = "tag"
+)
+
+// getUserGroups returns the groups of every user by the attribute, users without groups are left out
+func (p *Plugin) getUserGroups(users []string, attribute string) map[string][]string {
+	groups := make(map[string][]string)
+	tags := p.state.Get().Tags
+
+	for _, userID := range users {
+		switch attribute {
+		case mixingByPosition:
+			user, err := p.API.GetUser(userID)
+			if err != nil {
+				p.API.LogWarn(fmt.Sprintf("Failed to get the position of %s: %s", userID, err.Error()))
+				continue
+			}
+
+			if position := strings.ToLower(strings.TrimSpace(user.Position)); position != "" {
+				groups[userID] = []string{position}
+			}
+		case mixingByTeam:
+			teams, err := p.API.GetTeamsForUser(userID)
+			if err != nil {
+				p.API.LogWarn(fmt.Sprintf("Failed to get the teams of %s: %s", userID, err.Error()))
+				continue
+			}
+
+			for _, team := range teams {
+				groups[userID] = append(groups[userID], team.Id)
+			}
+		case mixingByTag:
+			if len(tags[userID]) > 0 {
+				groups[userID] = tags[userID]
+			}
+		}
+	}
+
+	return groups
+}
+
+// normalizeTags lowercases the tags and removes the duplicates
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !utils.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	sort.Strings(normalized)
+
+	return normalized
+}
+
+// setUserTags replaces the tags of the user, the user is removed from the tags if there are none
+func (p *Plugin) setUserTags(userID string, tags []string) error {
+	tags = normalizeTags(tags)
+
+	return p.updateState(func(state *State) error {
+		if state.Tags == nil {
+			state.Tags = make(map[string][]string)
+		}
+
+		if len(tags) == 0 {
+			delete(state.Tags, userID)
+		} else {
+			state.Tags[userID] = tags
+		}
+		return nil
+	})
+}
+
+// executeTagCommand runs the tag commands and returns the message for the admin
+func (p *Plugin) executeTagCommand(command string, args *model.CommandArgs, params []string) string {
+	tags := []string{}
+	for _, param := range params {
+		if !strings.HasPrefix(param, "@") {
+			tags = append(tags, param)
+		}
+	}
+
+	if command == "tags" {
+		state := p.state.Get()
+		users := []string{}
+		for userID := range state.Tags {
+			users = append(users, userID)
+		}
+
+		if len(users) == 0 {
+			return "There are no tags."
+		}
+
+		lines := []string{}
+		for _, userID := range users {
+			lines = append(lines, fmt.Sprintf(" - %s: %s", p.usernamesText([]string{userID}), strings.Join(state.Tags[userID], ", ")))
+		}
+		sort.Strings(lines)
+
+		return "Tags of the users:\n" + strings.Join(lines, "\n")
+	}
+
+	if len(args.UserMentions) == 0 || len(tags) == 0 {
+		return fmt.Sprintf("Mention the users and the tags, e.g. `/gather-plugin %s @alice sales`.", command)
+	}
+
+	for _, userID := range args.UserMentions {
+		current := p.state.Get().Tags[userID]
+		updated := append(append([]string{}, current...), tags...)
+
+		if command == "untag" {
+			updated = []string{}
+			for _, tag := range current {
+				if !utils.Contains(normalizeTags(tags), tag) {
+					updated = append(updated, tag)
+				}
+			}
+		}
+
+		if err := p.setUserTags(userID, updated); err != nil {
+			return "Failed to save the tags."
+		}
+	}
+
+	return "Tags saved."
+}
+
+// serveTagsAPI serves the tags of the users, only for admins
+func (p *Plugin) serveTagsAPI(req *apiRequest) {
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	switch {
+	case req.r.Method == http.MethodGet && len(req.path) == 1:
+		writeJSON(req.w, http.StatusOK, p.state.Get().Tags)
+	case req.r.Method == http.MethodPut && len(req.path) == 2:
+		tags := []string{}
+
+		if err := json.NewDecoder(req.r.Body).Decode(&tags); err != nil {
+			writeError(req.w, http.StatusBadRequest, "The body must be a list of tags.")
+			return
+		}
+
+		if err := p.setUserTags(req.path[1], tags); err != nil {
+			writeError(req.w, http.StatusInternalServerError, "Failed to save the tags.")
+			return
+		}
+
+		writeJSON(req.w, http.StatusOK, normalizeTags(tags))
+	default:
+		writeError(req.w, http.StatusNotFound, "Not found.")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchersMixGroups(t *testing.T) {
+	assert := assert.New(t)
+	crossed := func(input MatchInput, groups [][]string) bool {
+		for _, group := range groups {
+			if !input.isMixed(group[0], group[1]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// a and b are sales, c and d are engineering
+	input := MatchInput{
+		Users:      []string{"a", "b", "c", "d"},
+		Meetings:   map[string][]string{},
+		UserGroups: map[string][]string{"a": {"sales"}, "b": {"sales"}, "c": {"eng"}, "d": {"eng"}},
+		Mixing:     mixingMedium,
+	}
+
+	for _, mode := range []string{"greedy", "optimal"} {
+		for i := 0; i < 20; i++ {
+			result := newMatcher(mode).Match(input)
+			assert.Len(result.Groups, 2)
+			assert.True(crossed(input, result.Groups), "%s didn't mix %v", mode, result.Groups)
+		}
+	}
+
+	// everyone already met the other group, new partners come first unless the mixing is high
+	input.Meetings = map[string][]string{"a": {"c", "d"}, "b": {"c", "d"}, "c": {"a", "b"}, "d": {"a", "b"}}
+	for _, mode := range []string{"greedy", "optimal"} {
+		for _, mixing := range []string{mixingLow, mixingMedium} {
+			input.Mixing = mixing
+			result := newMatcher(mode).Match(input)
+			assert.False(crossed(input, result.Groups), "%s %s mixing repeated meetings %v", mode, mixing, result.Groups)
+		}
+
+		input.Mixing = mixingHigh
+		result := newMatcher(mode).Match(input)
+		assert.True(crossed(input, result.Groups), "%s didn't mix %v", mode, result.Groups)
+	}
+
+	// everyone met everyone and the own group longest ago, only medium and high mix them
+	input.Meetings = map[string][]string{"a": {"b", "c", "d"}, "b": {"a", "c", "d"}, "c": {"d", "a", "b"}, "d": {"c", "a", "b"}}
+	for _, mode := range []string{"greedy", "optimal"} {
+		for _, mixing := range []string{mixingLow, mixingMedium, mixingHigh} {
+			input.Mixing = mixing
+			result := newMatcher(mode).Match(input)
+			assert.Equal(mixing != mixingLow, crossed(input, result.Groups), "%s %s mixing %v", mode, mixing, result.Groups)
+		}
+	}
+}
+
+func TestTagCommands(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	run := func(userID string, command string, mentions ...string) string {
+		args := &model.CommandArgs{UserId: userID, Command: command}
+		for _, mention := range mentions {
+			args.AddUserMention(mention, mention)
+		}
+
+		response, err := p.ExecuteCommand(nil, args)
+		assert.Nil(err)
+		return response.Text
+	}
+
+	assert.Equal("Only system admins can do this.", run("alice", "/gather-plugin tag @bob sales", "bob"))
+	assert.Equal("There are no tags.", run("admin", "/gather-plugin tags"))
+	assert.Equal("Tags saved.", run("admin", "/gather-plugin tag @bob @carol Sales default", "bob", "carol"))
+	assert.Equal([]string{"default", "sales"}, p.state.Get().Tags["bob"])
+	assert.Contains(run("admin", "/gather-plugin tags"), "@carol: default, sales")
+
+	run("admin", "/gather-plugin untag @bob sales default", "bob")
+	_, ok := p.state.Get().Tags["bob"]
+	assert.False(ok)
+
+	p.setConfiguration(&configuration{MixingAttribute: mixingByTag})
+	input := p.matchInput(&Program{Name: defaultProgram, Users: []string{"bob", "carol"}})
+	assert.Equal(map[string][]string{"carol": {"default", "sales"}}, input.UserGroups)
+	assert.Equal(mixingMedium, input.Mixing)
+}
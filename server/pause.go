@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// pauseDurationRegexp matches the durations of a pause, e.g. 3d, 2w or 1m
+var pauseDurationRegexp = regexp.MustCompile(`^([1-9][0-9]{0,2})([dwm])$`)
+
+const pauseUsage = "Use `/gather-plugin pause` to pause until you unpause, `/gather-plugin pause 2w` for 2 weeks, `/gather-plugin pause until 2026-11-30` or `/gather-plugin skip 1` to skip the next round."
+
+// pauseEnd returns when a pause for the duration started at the time ends
+func pauseEnd(duration string, at time.Time) (time.Time, bool) {
+	match := pauseDurationRegexp.FindStringSubmatch(duration)
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	n, _ := strconv.Atoi(match[1])
+
+	switch match[2] {
+	case "d":
+		return at.AddDate(0, 0, n), true
+	case "w":
+		return at.AddDate(0, 0, 7*n), true
+	}
+
+	return at.AddDate(0, n, 0), true
+}
+
+// pauseArgsCount returns how many of the params are the arguments of the pause and skip commands
+func pauseArgsCount(command string, params []string) int {
+	if len(params) == 0 {
+		return 0
+	}
+
+	if command == "pause" && params[0] == "until" && len(params) > 1 {
+		return 2
+	}
+
+	return 1
+}
+
+// setPause pauses the user, until is the time the pause ends and rounds the number of rounds to
+// skip, without them the user is paused until they unpause
+func (p *Plugin) setPause(name string, userID string, until time.Time, rounds int) error {
+	return p.updateProgram(name, func(program *Program) error {
+		clearPause(program, userID)
+		program.Paused = append(program.Paused, userID)
+
+		if !until.IsZero() {
+			if program.PausedUntil == nil {
+				program.PausedUntil = make(map[string]int64)
+			}
+			program.PausedUntil[userID] = timeToMillis(until)
+		}
+
+		if rounds > 0 {
+			if program.SkipRounds == nil {
+				program.SkipRounds = make(map[string]int)
+			}
+			program.SkipRounds[userID] = rounds
+		}
+		return nil
+	})
+}
+
+// clearPause unpauses the user
+func clearPause(program *Program, userID string) {
+	program.Paused = utils.Remove(program.Paused, userID)
+	delete(program.PausedUntil, userID)
+	delete(program.SkipRounds, userID)
+}
+
+// pauseText describes when the pause of a paused user ends, the date is shown in the location of
+// the user, UTC if it's nil
+func pauseText(program *Program, userID string, location *time.Location) string {
+	if location == nil {
+		location = time.UTC
+	}
+
+	if until, ok := program.PausedUntil[userID]; ok {
+		return "paused until " + time.Unix(0, until*int64(time.Millisecond)).In(location).Format("2006-01-02")
+	}
+
+	if rounds, ok := program.SkipRounds[userID]; ok {
+		if rounds == 1 {
+			return "paused for 1 round"
+		}
+		return fmt.Sprintf("paused for %d rounds", rounds)
+	}
+
+	return "paused"
+}
+
+// executePauseCommand toggles the pause of the user or pauses them for a while
+func (p *Plugin) executePauseCommand(command string, program *Program, userID string, params []string) string {
+	var until time.Time
+	rounds := 0
+	location := p.getUserLocations([]string{userID})[userID]
+
+	switch {
+	case command == "skip":
+		n, err := strconv.Atoi(firstParam(params))
+		if err != nil || n < 1 {
+			return pauseUsage
+		}
+		rounds = n
+	case len(params) == 0:
+		now := time.Now()
+		err := p.updateProgram(program.Name, func(program *Program) error {
+			paused := program.IsPaused(userID, now)
+			clearPause(program, userID)
+
+			if !paused {
+				program.Paused = append(program.Paused, userID)
+			}
+			return nil
+		})
+
+		if err != nil {
+			return "Failed to save your pause, contact your administrator."
+		}
+
+		if program.IsPaused(userID, now) {
+			return "Gather plugin unpaused."
+		}
+		return "Gather plugin paused."
+	case params[0] == "until":
+		if location == nil {
+			location = time.UTC
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", firstParam(params[1:]), location)
+		if err != nil {
+			return pauseUsage
+		}
+		until = date
+	default:
+		end, ok := pauseEnd(params[0], time.Now())
+		if !ok {
+			return pauseUsage
+		}
+		until = end
+	}
+
+	if !until.IsZero() && !until.After(time.Now()) {
+		return "The pause must end in the future."
+	}
+
+	if err := p.setPause(program.Name, userID, until, rounds); err != nil {
+		return "Failed to save your pause, contact your administrator."
+	}
+
+	updated, _ := p.getProgram(program.Name)
+	return fmt.Sprintf("Gather plugin %s.", pauseText(updated, userID, location))
+}
+
+// firstParam returns the first param or an empty string if there are none
+func firstParam(params []string) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	return params[0]
+}
+
+// resumeUsers unpauses the users whose pause ended at the time, if a round just ran the users
+// skipping rounds have one round less to skip. The resumed users get a direct message.
+func (p *Plugin) resumeUsers(name string, at time.Time, roundRan bool) {
+	var resumed []string
+
+	err := p.updateProgram(name, func(program *Program) error {
+		resumed = []string{}
+
+		for userID, until := range program.PausedUntil {
+			if until <= timeToMillis(at) {
+				clearPause(program, userID)
+				resumed = append(resumed, userID)
+			}
+		}
+
+		if roundRan {
+			for userID, rounds := range program.SkipRounds {
+				if rounds <= 1 {
+					clearPause(program, userID)
+					resumed = append(resumed, userID)
+				} else {
+					program.SkipRounds[userID] = rounds - 1
+				}
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		if err != errProgramNotFound {
+			p.API.LogError(fmt.Sprintf("Failed to resume the paused users: %s", err.Error()))
+		}
+		return
+	}
+
+	for _, userID := range resumed {
+		p.sendResumeMessage(name, userID)
+	}
+}
+
+// sendResumeMessage tells the user their pause ended
+func (p *Plugin) sendResumeMessage(name string, userID string) {
+	channel, err := p.API.GetDirectChannel(p.botUserID, userID)
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to get the direct channel of %s: %s", userID, err.Error()))
+		return
+	}
+
+	message := "Your pause is over, you will meet someone in the next round."
+	if name != defaultProgram {
+		message = fmt.Sprintf("Your pause in the program %s is over, you will meet someone in the next round.", name)
+	}
+
+	_, err = p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   message,
+	})
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to send the end of the pause to %s: %s", userID, err.Error()))
+	}
+}
+
+// pauseRequest is the optional body of a pause, the date in milliseconds the pause ends or the
+// number of rounds to skip
+type pauseRequest struct {
+	Until  int64 `json:"until"`
+	Rounds int   `json:"rounds"`
+}
+
+// apiPause pauses a participant, until they are unpaused if there is no body
+func (p *Plugin) apiPause(req *apiRequest, program *Program, userID string) {
+	if !p.requireSelfOrAdmin(req, userID) {
+		return
+	}
+
+	if !utils.Contains(program.Users, userID) {
+		writeError(req.w, http.StatusNotFound, fmt.Sprintf("The user %s is not a participant of the program %s.", userID, program.Name))
+		return
+	}
+
+	body := pauseRequest{}
+	if err := json.NewDecoder(req.r.Body).Decode(&body); err != nil && err != io.EOF {
+		writeError(req.w, http.StatusBadRequest, "The body must be empty, {\"until\": <milliseconds>} or {\"rounds\": <rounds>}.")
+		return
+	}
+
+	var until time.Time
+	if body.Until != 0 {
+		until = time.Unix(0, body.Until*int64(time.Millisecond))
+	}
+
+	if body.Rounds < 0 || (body.Until != 0 && !until.After(time.Now())) {
+		writeError(req.w, http.StatusBadRequest, "The pause must end in the future.")
+		return
+	}
+
+	if err := p.setPause(program.Name, userID, until, body.Rounds); err != nil {
+		writeError(req.w, http.StatusInternalServerError, "Failed to save the pause.")
+		return
+	}
+
+	program, _ = p.getProgram(program.Name)
+	writeJSON(req.w, http.StatusOK, newParticipantResponse(program, userID))
+}
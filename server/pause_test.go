@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPauseEnd(t *testing.T) {
+	assert := assert.New(t)
+	at := time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	end, ok := pauseEnd("3d", at)
+	assert.True(ok)
+	assert.Equal(time.Date(2026, time.February, 3, 9, 0, 0, 0, time.UTC), end)
+
+	end, _ = pauseEnd("2w", at)
+	assert.Equal(time.Date(2026, time.February, 14, 9, 0, 0, 0, time.UTC), end)
+
+	end, _ = pauseEnd("1m", at)
+	assert.Equal(time.Date(2026, time.March, 3, 9, 0, 0, 0, time.UTC), end)
+
+	for _, duration := range []string{"", "0d", "2", "w", "2y", "-1d"} {
+		_, ok = pauseEnd(duration, at)
+		assert.False(ok, duration)
+	}
+}
+
+func TestTimedPause(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	run := func(userID string, command string) string {
+		response, err := p.ExecuteCommand(nil, &model.CommandArgs{UserId: userID, Command: command})
+		assert.Nil(err)
+		return response.Text
+	}
+
+	for _, userID := range []string{"alice", "bob", "carol", "dave"} {
+		run(userID, "/gather-plugin on")
+	}
+
+	assert.Equal("Gather plugin paused.", run("alice", "/gather-plugin pause"))
+	assert.Equal("Gather plugin unpaused.", run("alice", "/gather-plugin pause"))
+
+	assert.Equal("Gather plugin paused until 2099-11-30.", run("alice", "/gather-plugin pause until 2099-11-30"))
+	assert.Equal("The pause must end in the future.", run("bob", "/gather-plugin pause until 2000-01-01"))
+	assert.Equal(pauseUsage, run("bob", "/gather-plugin pause 2y"))
+	assert.Equal("The program sales doesn't exist.", run("bob", "/gather-plugin pause 2w sales"))
+	assert.Contains(run("bob", "/gather-plugin pause 2w"), "Gather plugin paused until ")
+	assert.Equal("Gather plugin paused for 2 rounds.", run("carol", "/gather-plugin skip 2"))
+	assert.Equal(pauseUsage, run("carol", "/gather-plugin skip 0"))
+
+	info := run("admin", "/gather-plugin info")
+	assert.Contains(info, "(@alice) paused until 2099-11-30")
+	assert.Contains(info, "(@carol) paused for 2 rounds")
+
+	// unpausing clears the end of the pause
+	run("alice", "/gather-plugin pause")
+	program, _ := p.getProgram(defaultProgram)
+	assert.Empty(program.PausedUntil["alice"])
+
+	// bob's pause ended before the round, carol skips two rounds
+	p.updateProgram(defaultProgram, func(program *Program) error {
+		program.PausedUntil["bob"] = timeToMillis(time.Now().Add(-time.Minute))
+		return nil
+	})
+
+	// the expired pause is over before any round lifts it
+	program, _ = p.getProgram(defaultProgram)
+	assert.False(program.IsPaused("bob", time.Now()))
+	assert.ElementsMatch([]string{"alice", "bob", "dave"}, program.ActiveUsers(time.Now()))
+	assert.NotContains(run("admin", "/gather-plugin info"), "(@bob) paused")
+	assert.Equal(1, computeStats(program, time.Now()).Participants.Paused)
+
+	p.runMeetings(defaultProgram, "1", time.Now(), "")
+	program, _ = p.getProgram(defaultProgram)
+	assert.Equal([]string{"carol"}, program.Paused)
+	assert.Equal(1, program.SkipRounds["carol"])
+
+	p.runMeetings(defaultProgram, "2", time.Now(), "")
+	program, _ = p.getProgram(defaultProgram)
+	assert.Empty(program.Paused)
+	assert.Empty(program.SkipRounds)
+
+	api := p.API.(*plugintest.API)
+	api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "dm_bob" && post.Message == "Your pause is over, you will meet someone in the next round."
+	}))
+	api.AssertCalled(t, "CreatePost", mock.MatchedBy(func(post *model.Post) bool {
+		return post.ChannelId == "dm_carol"
+	}))
+}
+
+func TestPauseTextInUserTimezone(t *testing.T) {
+	assert := assert.New(t)
+	tokyo := time.FixedZone("JST", 9*60*60)
+	program := NewProgram(defaultProgram)
+	program.Paused = []string{"alice"}
+	program.PausedUntil["alice"] = timeToMillis(time.Date(2026, time.November, 3, 0, 0, 0, 0, tokyo))
+
+	assert.Equal("paused until 2026-11-03", pauseText(program, "alice", tokyo))
+	assert.Equal("paused until 2026-11-02", pauseText(program, "alice", nil))
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"fmt"
-	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/juanfran/mattermost-gather-users/server/utils"
 	"github.com/mattermost/mattermost-server/v5/model"
@@ -23,30 +23,24 @@ type Plugin struct {
 	// setConfiguration for usage.
 	configuration *configuration
 
-	cron *cron.Cron
+	// cronLock synchronizes the changes of the cron jobs, they are rescheduled when the programs change.
+	cronLock sync.Mutex
+	cron     *cron.Cron
 
-	users         []string
-	paused        []string
-	usersMeetings map[string][]string
-	oddUserTurn   []string
-
-	meetInCron    []string
-	oddUserInCron string
+	// state is shared by the hooks, the commands and the cron jobs, consult stateManager for usage.
+	state *stateManager
 
 	botUserID string
 }
 
-// Meeting the way to store meeting
-type Meeting struct {
-	User1 string `json:"user1"`
-	User2 string `json:"user2"`
-}
-
 // UserHasLeftTeam one user left the team
 func (p *Plugin) UserHasLeftTeam(c *plugin.Context, teamMember *model.TeamMember) {
-	p.users = utils.Remove(p.users, teamMember.UserId)
-	p.removeUserMeetings(teamMember.UserId)
-	p.persistMeetings()
+	p.updateState(func(state *State) error {
+		for _, program := range state.Programs {
+			removeProgramUser(program, teamMember.UserId)
+		}
+		return nil
+	})
 }
 
 func (p *Plugin) refreshCron(configuration *configuration) {
@@ -54,6 +48,15 @@ func (p *Plugin) refreshCron(configuration *configuration) {
 }
 
 func (p *Plugin) addCronFunc() {
+	p.cronLock.Lock()
+	defer p.cronLock.Unlock()
+
+	// the configuration is loaded before the plugin is activated, the programs are scheduled once
+	// OnActivate loads the state
+	if p.state == nil {
+		return
+	}
+
 	if p.cron != nil {
 		p.removeCron()
 	} else {
@@ -62,261 +65,187 @@ func (p *Plugin) addCronFunc() {
 		p.cron.Start()
 	}
 
-	config := p.getConfiguration()
-	configCron := config.Cron
-
-	if configCron == "" {
-		configCron = "@weekly"
-	}
-	if config.Cron == "custom" && len(config.CustomCron) > 0 {
-		configCron = config.CustomCron
-	}
-	crontList := strings.Split(configCron, ",")
+	for _, program := range p.state.Get().Programs {
+		name := program.Name
 
-	for _, cron := range crontList {
-		var err error
+		for _, cron := range p.getCronSpecs(program) {
+			var err error
 
-		// every minute "* * * * *"
-		_, err = p.cron.AddFunc(cron, func() {
-			p.runMeetings()
-		})
+			// every minute "* * * * *"
+			_, err = p.cron.AddFunc(cron, func() {
+				p.runScheduledRound(name)
+			})
 
-		if err != nil {
-			fmt.Println(err)
+			if err != nil {
+				p.API.LogError(fmt.Sprintf("Failed to schedule the program %s: %s", name, err.Error()))
+			}
 		}
 	}
 
-	// for _, entry := range p.cron.Entries() {
-	// 	fmt.Println("entry")
-	// 	fmt.Println(entry.Next)
-	// }
-}
-
-func (p *Plugin) hasRemeaningMeetings(userId string) bool {
-	availableUsers := p.getAvailableUsers()
-	availableUsersSize := len(availableUsers)
-
-	return len(p.usersMeetings[userId]) < (availableUsersSize - 1)
-}
-
-func (p *Plugin) printMeetInCron() {
-	result := []string{}
-
-	for _, userId := range p.meetInCron {
-		userData, _ := p.API.GetUser(userId)
-		result = append(result, userData.Username)
-	}
-}
-
-func (p *Plugin) fillOddUserTurnList() {
-	for _, userId := range p.users {
-		if !utils.Contains(p.oddUserTurn, userId) {
-			p.oddUserTurn = append(p.oddUserTurn, userId)
+	if p.getConfiguration().ConfirmRounds {
+		if _, err := p.cron.AddFunc(pendingRoundsSchedule, p.runPendingRounds); err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to schedule the cutoff of the rounds: %s", err.Error()))
 		}
 	}
-}
 
-func (p *Plugin) getOddUserInCron() string {
-	availableUsers := p.getAvailableUsers()
-
-	for _, userId := range p.oddUserTurn {
-		if !utils.Contains(p.paused, userId) && utils.Contains(availableUsers, userId) {
-			return userId
+	if p.getConfiguration().getFollowUpDays() > 0 {
+		if _, err := p.cron.AddFunc(followUpsSchedule, p.sendFollowUps); err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to schedule the follow-ups of the meetings: %s", err.Error()))
 		}
 	}
-
-	return p.oddUserTurn[0]
 }
 
-func (p *Plugin) runMeetings() {
-	p.cleanUsers()
-
-	p.meetInCron = []string{}
-	p.oddUserInCron = ""
-	usersWithoutPendingMeetings := []string{}
-	usersWithPendingMeetings := []string{}
-
-	availableUsers := p.getAvailableUsers()
-	isOdd := (len(availableUsers) % 2) != 0
-
-	if isOdd {
-		p.fillOddUserTurnList()
-		p.oddUserInCron = p.getOddUserInCron()
-		p.oddUserTurn = utils.Remove(p.oddUserTurn, p.oddUserInCron)
-		p.oddUserTurn = append(p.oddUserTurn, p.oddUserInCron)
-		p.persistOddUserTurn()
-	}
-
-	availableUsers = p.getAvailableUsers()
-
-	utils.ShuffleUsers(availableUsers)
-
-	sort.SliceStable(availableUsers, func(i, j int) bool {
-		return len(p.usersMeetings[availableUsers[i]]) < len(p.usersMeetings[availableUsers[j]])
-	})
-
-	for _, userId := range availableUsers {
-		_, ok := p.usersMeetings[userId]
-		if !ok {
-			p.usersMeetings[userId] = []string{}
-		}
-
-		if p.hasRemeaningMeetings(userId) {
-			userToMeet, ok := p.findUserToMeet(userId)
-
-			if ok {
-				p.startMeeting(userId, userToMeet)
-			} else {
-				usersWithPendingMeetings = append(usersWithPendingMeetings, userId)
-			}
-		} else {
-			usersWithoutPendingMeetings = append(usersWithoutPendingMeetings, userId)
-		}
-	}
-
-	for _, userId := range usersWithPendingMeetings {
-		userToMeet, ok := p.findAnyUserToMeet(userId)
+func (p *Plugin) getMatcher() Matcher {
+	return newMatcher(p.getConfiguration().Matching)
+}
 
-		if ok {
-			p.startMeeting(userId, userToMeet)
-		}
+func (p *Plugin) matchInput(program *Program) MatchInput {
+	config := p.getConfiguration()
+	attribute, strength := config.getMixing()
+	userGroups := map[string][]string{}
+	offsets := map[string]int{}
+	now := time.Now()
+	activeUsers := program.ActiveUsers(now)
+	dueUsers := program.DueUsers(now)
+
+	if attribute != "" {
+		userGroups = p.getUserGroups(activeUsers, attribute)
 	}
 
-	for _, userId := range usersWithoutPendingMeetings {
-		userToMeet, ok := p.findUserToMeet(userId)
-
-		if ok {
-			p.startMeeting(userId, userToMeet)
-		}
+	if config.getMinimumOverlap() > 0 {
+		offsets = p.getUserOffsets(activeUsers, now)
 	}
 
-	for _, userId := range usersWithoutPendingMeetings {
-		userToMeet, ok := p.findAnyUserToMeet(userId)
-
-		if ok {
-			p.startMeeting(userId, userToMeet)
-		}
+	start, end := config.getWorkingHours()
+
+	return MatchInput{
+		Users:        dueUsers,
+		Paused:       program.PausedUsers(now),
+		Meetings:     p.matchingHistory(program.History).Adjacency(utils.Intersect(dueUsers, activeUsers)),
+		OddUserTurn:  program.OddUserTurn,
+		GroupSize:    config.getGroupSize(),
+		OddPolicy:    config.getOddUserPolicy(),
+		Volunteer:    p.getVolunteerID(),
+		Blocked:      p.state.Get().Blocked(),
+		UserGroups:   userGroups,
+		Mixing:       strength,
+		Offsets:      offsets,
+		WorkingHours: [2]int{start, end},
+		MinOverlap:   config.getMinimumOverlap(),
 	}
-
-	p.persistMeetings()
 }
 
-func (p *Plugin) userHasMeetings(userID string) bool {
-	return len(p.usersMeetings[userID]) > 0
-}
+// roundInput returns the input to match the round, the expired pauses are already over and only
+// the users that joined a round waiting for confirmations meet
+func (p *Plugin) roundInput(program *Program, roundID string) MatchInput {
+	input := p.matchInput(program)
 
-func (p *Plugin) removeUserMeetings(userID string) {
-	for _, i := range p.users {
-		p.usersMeetings[i] = utils.Remove(p.usersMeetings[i], userID)
+	if pending := program.PendingRound; pending != nil && pending.RoundID == roundID {
+		input.Users = utils.Intersect(input.Users, pending.Confirmed)
 	}
 
-	delete(p.usersMeetings, userID)
+	return input
 }
 
-func (p *Plugin) getAvailableUsers() []string {
-	var users []string
+// matchingHistory returns the meetings that count when pairing users, older meetings are forgotten
+func (p *Plugin) matchingHistory(history History) History {
+	window, unit := p.getConfiguration().getHistoryWindow()
 
-	for _, userId := range p.users {
-		if !utils.Contains(p.paused, userId) && userId != p.oddUserInCron {
-			users = append(users, userId)
-		}
+	if window > 0 && unit == "days" {
+		history = history.Since(model.GetMillis() - int64(window)*24*int64(time.Hour/time.Millisecond))
+	} else if window > 0 {
+		history = history.LastRounds(window)
 	}
 
-	return users
-}
-
-func (p *Plugin) isUserInTheCurrentCron(userID string) bool {
-	return utils.Contains(p.meetInCron, userID)
-}
-
-func (p *Plugin) findUserToMeet(userID string) (string, bool) {
-	if p.isUserInTheCurrentCron(userID) {
-		return "", false
+	// the users that didn't meet can be paired again
+	if p.getConfiguration().RepairUnmet {
+		history = history.WithoutUnmet()
 	}
 
-	availableUsers := p.getAvailableUsers()
-	userMeetings := p.usersMeetings[userID]
+	return history
+}
 
-	utils.ShuffleUsers(availableUsers)
+// getVolunteerID returns the user that meets the odd user when the odd user policy is volunteer
+func (p *Plugin) getVolunteerID() string {
+	config := p.getConfiguration()
 
-	// find if the user haven't meet someone
-	for _, pairUserID := range availableUsers {
-		if pairUserID != userID &&
-			!p.isUserInTheCurrentCron(pairUserID) &&
-			!utils.Contains(userMeetings, pairUserID) {
-			return pairUserID, true
-		}
+	if config.getOddUserPolicy() != oddPolicyVolunteer || config.OddUserVolunteer == "" {
+		return ""
 	}
 
-	// get user from previous meetings
-	userToMeet, ok := p.getUserWithoutMeeting(userMeetings)
-
-	if ok {
-		return userToMeet, true
+	username := strings.TrimPrefix(config.OddUserVolunteer, "@")
+	user, err := p.API.GetUserByUsername(username)
+	if err != nil {
+		p.API.LogWarn(fmt.Sprintf("Failed to find the volunteer %s: %s", username, err.Error()))
+		return ""
 	}
 
-	return "", false
+	return user.Id
 }
 
-func (p *Plugin) getUserWithoutMeeting(users []string) (string, bool) {
-	for _, userId := range users {
-		if !p.isUserInTheCurrentCron(userId) {
-			return userId, true
-		}
-	}
-
-	return "", false
-}
+// runMeetings pairs the users of the program and starts their meetings, it returns the meetings started.
+// triggeredBy is the admin that ran the round by hand, if any.
+func (p *Plugin) runMeetings(name string, roundID string, at time.Time, triggeredBy string) History {
+	p.resumeUsers(name, at, false)
 
-func (p *Plugin) findAnyUserToMeet(userID string) (string, bool) {
-	if p.isUserInTheCurrentCron(userID) {
-		return "", false
+	program, ok := p.getProgram(name)
+	if !ok {
+		return History{}
 	}
 
-	availableUsers := p.getAvailableUsers()
-	utils.ShuffleUsers(availableUsers)
+	result := p.getMatcher().Match(p.roundInput(program, roundID))
 
-	for _, pairUserID := range availableUsers {
-		if pairUserID != userID && !p.isUserInTheCurrentCron(pairUserID) {
-			return pairUserID, true
-		}
+	records := History{}
+	for _, group := range result.Groups {
+		records = append(records, p.startMeeting(program, roundID, group))
 	}
 
-	return "", false
-}
-
-func (p *Plugin) cleanUsers() {
-	availableUsers := p.getAvailableUsers()
-
-	mettings := make(map[string][]string)
+	err := p.updateProgram(name, func(program *Program) error {
+		// users may have left while the meetings were being started
+		program.OddUserTurn = utils.Intersect(result.OddUserTurn, program.Users)
+		program.History = append(program.History, records...)
+		program.Rounds = append(program.Rounds, newRoundSnapshot(program, roundID, at, triggeredBy))
+		program.LastRoundID = roundID
+		program.LastRoundAt = timeToMillis(at)
+		program.OddUser = result.OddUser
+
+		if program.PendingRound != nil && program.PendingRound.RoundID == roundID {
+			program.PendingRound = nil
+		}
 
-	for _, user := range p.users {
-		_, ok := mettings[user]
-		if !ok {
-			mettings[user] = []string{}
+		if program.LastPaired == nil {
+			program.LastPaired = make(map[string]*PairedRound)
 		}
 
-		for _, userId := range p.usersMeetings[user] {
-			if utils.Contains(availableUsers, userId) {
-				mettings[user] = append(mettings[user], userId)
+		for _, record := range records {
+			for _, userID := range record.Participants {
+				program.LastPaired[userID] = &PairedRound{RoundID: roundID, Timestamp: record.Timestamp}
 			}
 		}
+		return nil
+	})
+
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to save the round %s: %s", roundID, err.Error()))
 	}
 
-	p.usersMeetings = mettings
+	p.resumeUsers(name, at, true)
+
+	return records
 }
 
-func (p *Plugin) usersMeetingsByUsername() map[string][]string {
+func (p *Plugin) usersMeetingsByUsername(program *Program) map[string][]string {
 	mettings := make(map[string][]string)
+	usersMeetings := program.History.Adjacency(program.Users)
 
-	for _, user := range p.users {
+	for _, user := range program.Users {
 		mainUserData, _ := p.API.GetUser(user)
 		_, ok := mettings[mainUserData.Username]
 		if !ok {
 			mettings[mainUserData.Username] = []string{}
 		}
 
-		for _, userMeeting := range p.usersMeetings[user] {
+		for _, userMeeting := range usersMeetings[user] {
 			userData, _ := p.API.GetUser(userMeeting)
 			mettings[mainUserData.Username] = append(mettings[mainUserData.Username], userData.Username)
 		}
@@ -325,54 +254,95 @@ func (p *Plugin) usersMeetingsByUsername() map[string][]string {
 	return mettings
 }
 
-func (p *Plugin) startMeeting(userID string, pairUserID string) {
-	newUserMeetings := utils.Remove(p.usersMeetings[userID], pairUserID)
-	p.usersMeetings[userID] = append(newUserMeetings, pairUserID)
+// startMeeting creates the group channel of the meeting and posts the initial text, it returns the
+// record of the meeting to be added to the history
+func (p *Plugin) startMeeting(program *Program, roundID string, users []string) *MeetingRecord {
+	record := &MeetingRecord{
+		RoundID:      roundID,
+		Timestamp:    model.GetMillis(),
+		Participants: users,
+	}
 
-	newUserMeetings = utils.Remove(p.usersMeetings[pairUserID], userID)
-	p.usersMeetings[pairUserID] = append(newUserMeetings, userID)
+	channel, err := p.API.GetGroupChannel(append([]string{p.botUserID}, users...))
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to create the meeting channel: %s", err.Error()))
+	} else {
+		post := &model.Post{
+			UserId:    p.botUserID,
+			ChannelId: channel.Id,
+			Message:   p.introMessage(program, roundID, users),
+		}
 
-	p.meetInCron = append(p.meetInCron, userID, pairUserID)
+		if overlap := p.overlapText(users, time.Now()); overlap != "" {
+			post.Message += "\n\n" + overlap
+		}
 
-	users := []string{p.botUserID, userID, pairUserID}
+		record.ChannelID = channel.Id
+		record.FollowUpAt = p.getConfiguration().followUpAt(record.Timestamp)
 
-	channel, _ := p.API.GetGroupChannel(users)
+		post, err = p.API.CreatePost(post)
+		if err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to post the meeting message: %s", err.Error()))
+		} else {
+			record.PostID = post.Id
+		}
+	}
 
-	config := p.getConfiguration()
+	return record
+}
 
-	post := &model.Post{
-		UserId:    p.botUserID,
-		ChannelId: channel.Id,
-		Message:   config.InitText,
+func (p *Plugin) addUser(name string, userID string) error {
+	if program, ok := p.getProgram(name); ok && utils.Contains(program.Users, userID) {
+		return nil
 	}
 
-	p.persistMeetings()
-	p.API.CreatePost(post)
-}
+	err := p.updateProgram(name, func(program *Program) error {
+		if !utils.Contains(program.Users, userID) {
+			program.Users = append(program.Users, userID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-func (p *Plugin) addUser(userID string) {
-	if !utils.Contains(p.users, userID) {
-		p.users = append(p.users, userID)
+	config := p.getConfiguration()
+	program, ok := p.getProgram(name)
 
-		config := p.getConfiguration()
+	// meet now only if the user has no previous meetings
+	if ok && config.FirstMeeting && !program.History.HasMeetings(userID) {
+		busy := append([]string{program.OddUser}, program.History.RoundParticipants(program.LastRoundID)...)
+		userToMeet, found := findFirstMeeting(p.matchInput(program), userID, busy)
 
-		// meet now only if the user has no previous meetings
-		if config.FirstMeeting && !p.userHasMeetings(userID) {
-			userToMeet, ok := p.findUserToMeet(userID)
+		if found {
+			record := p.startMeeting(program, program.LastRoundID, []string{userID, userToMeet})
 
-			if ok {
-				p.startMeeting(userID, userToMeet)
-			}
+			return p.updateProgram(name, func(program *Program) error {
+				program.History = program.History.Add(record)
+				return nil
+			})
 		}
 	}
+
+	return nil
+}
+
+func (p *Plugin) removeUser(name string, userID string) error {
+	return p.updateProgram(name, func(program *Program) error {
+		removeProgramUser(program, userID)
+		return nil
+	})
 }
 
-func (p *Plugin) removeUser(userID string) {
-	p.users = utils.Remove(p.users, userID)
-	p.meetInCron = utils.Remove(p.meetInCron, userID)
-	p.oddUserTurn = utils.Remove(p.oddUserTurn, userID)
-	p.removeUserMeetings(userID)
-	p.persistMeetings()
+// removeProgramUser removes the user and their meetings from the program
+func removeProgramUser(program *Program, userID string) {
+	program.Users = utils.Remove(program.Users, userID)
+	program.OddUserTurn = utils.Remove(program.OddUserTurn, userID)
+	program.History = program.History.RemoveUser(userID)
+	delete(program.Frequencies, userID)
+	delete(program.LastPaired, userID)
+	delete(program.PausedUntil, userID)
+	delete(program.SkipRounds, userID)
 }
 
 func (p *Plugin) removeCron() {
@@ -385,5 +355,5 @@ func (p *Plugin) removeCron() {
 func (p *Plugin) OnDeactivate() error {
 	p.removeCron()
 
-	return p.persistUsers()
+	return nil
 }
@@ -6,14 +6,16 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestServeHTTP(t *testing.T) {
 	assert := assert.New(t)
 	plugin := Plugin{}
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/programs", nil)
 
 	plugin.ServeHTTP(nil, w, r)
 
@@ -24,5 +26,22 @@ func TestServeHTTP(t *testing.T) {
 	assert.Nil(err)
 	bodyString := string(bodyBytes)
 
-	assert.Equal("Hello, world!", bodyString)
+	assert.Equal(http.StatusUnauthorized, result.StatusCode)
+	assert.JSONEq(`{"error": "Not authorized."}`, bodyString)
+
+	w = httptest.NewRecorder()
+	plugin.ServeHTTP(nil, w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
+func TestOnConfigurationChangeBeforeActivation(t *testing.T) {
+	assert := assert.New(t)
+	api := &plugintest.API{}
+	api.On("LoadPluginConfiguration", mock.Anything).Return(nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	assert.Nil(p.OnConfigurationChange())
+	assert.Nil(p.cron)
 }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RoundPreview is what the next round of a program would be, nothing is created nor saved
+type RoundPreview struct {
+	Groups  [][]string       `json:"groups"`
+	OddUser string           `json:"odd_user"`
+	Repeats []*RepeatMeeting `json:"repeats"`
+
+	// Unmatched are the users the block rules leave without a group
+	Unmatched []string `json:"unmatched"`
+}
+
+// RepeatMeeting is a pair of users of a previewed group that already met
+type RepeatMeeting struct {
+	Users     []string `json:"users"`
+	RoundID   string   `json:"round_id"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// previewRound runs the matching of the program on a copy of its state, the next round is the one
+// waiting for confirmations if there is one
+func (p *Plugin) previewRound(program *Program) *RoundPreview {
+	program = program.Clone()
+
+	roundID := ""
+	if program.PendingRound != nil {
+		roundID = program.PendingRound.RoundID
+	}
+
+	result := p.getMatcher().Match(p.roundInput(program, roundID))
+
+	preview := &RoundPreview{
+		Groups:    [][]string{},
+		OddUser:   result.OddUser,
+		Repeats:   []*RepeatMeeting{},
+		Unmatched: append([]string{}, result.Unmatched...),
+	}
+
+	for _, group := range result.Groups {
+		preview.Groups = append(preview.Groups, group)
+
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				if record, ok := program.History.LastMeeting(group[i], group[j]); ok {
+					preview.Repeats = append(preview.Repeats, &RepeatMeeting{
+						Users:     []string{group[i], group[j]},
+						RoundID:   record.RoundID,
+						Timestamp: record.Timestamp,
+					})
+				}
+			}
+		}
+	}
+
+	return preview
+}
+
+// previewText describes the preview for the admins
+func (p *Plugin) previewText(name string, preview *RoundPreview) string {
+	var msgBuilder strings.Builder
+
+	msgBuilder.WriteString(fmt.Sprintf("Next round of the program %s:\n", name))
+
+	if len(preview.Groups) == 0 {
+		msgBuilder.WriteString("Nobody would meet.\n")
+	}
+
+	for _, group := range preview.Groups {
+		msgBuilder.WriteString(fmt.Sprintf(" - %s\n", p.usernamesText(group)))
+	}
+
+	if preview.OddUser != "" {
+		msgBuilder.WriteString(fmt.Sprintf("Sitting out: %s\n", p.usernamesText([]string{preview.OddUser})))
+	}
+
+	if len(preview.Unmatched) > 0 {
+		msgBuilder.WriteString(fmt.Sprintf("Without a group because of the block rules: %s\n", p.usernamesText(preview.Unmatched)))
+	}
+
+	if len(preview.Repeats) > 0 {
+		msgBuilder.WriteString("Repeat meetings:\n")
+
+		for _, repeat := range preview.Repeats {
+			when := "before the meeting dates were recorded"
+			if repeat.RoundID != legacyRoundID {
+				when = "on " + time.Unix(0, repeat.Timestamp*int64(time.Millisecond)).UTC().Format("2006-01-02")
+			}
+
+			msgBuilder.WriteString(fmt.Sprintf(" - %s last met %s\n", p.usernamesText(repeat.Users), when))
+		}
+	}
+
+	return msgBuilder.String()
+}
+
+// usernamesText lists the users as mentions, e.g. "@alice, @bob and @carol"
+func (p *Plugin) usernamesText(users []string) string {
+	mentions := []string{}
+
+	for _, userID := range users {
+		user, err := p.API.GetUser(userID)
+		if err != nil {
+			mentions = append(mentions, userID)
+		} else {
+			mentions = append(mentions, "@"+user.Username)
+		}
+	}
+
+	if len(mentions) <= 1 {
+		return strings.Join(mentions, "")
+	}
+
+	return strings.Join(mentions[:len(mentions)-1], ", ") + " and " + mentions[len(mentions)-1]
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewRound(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	program := NewProgram(defaultProgram)
+	program.Users = []string{"a", "b", "c"}
+	program.History = History{{RoundID: "20261005T0900Z", Timestamp: 1759654800000, Participants: []string{"a", "b"}}}
+
+	preview := p.previewRound(program)
+	assert.Len(preview.Groups, 1)
+	assert.NotEmpty(preview.OddUser)
+	assert.Empty(preview.Repeats)
+
+	// nothing is saved
+	assert.Empty(program.OddUserTurn)
+	assert.Len(program.History, 1)
+
+	program.Users = []string{"a", "b"}
+	preview = p.previewRound(program)
+	assert.Len(preview.Repeats, 1)
+
+	text := p.previewText(defaultProgram, preview)
+	assert.Regexp(`- @(a and @b|b and @a)\n`, text)
+	assert.Regexp(`@(a and @b|b and @a) last met on 2025-10-05`, text)
+
+	assert.Equal("@a, @b and @c", p.usernamesText([]string{"a", "b", "c"}))
+}
+
+func TestPreviewRoundMatchesTheNextRound(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+
+	// d's pause is over and only a, b and d joined the round waiting for confirmations
+	program := NewProgram(defaultProgram)
+	program.Users = []string{"a", "b", "c", "d", "e"}
+	program.Paused = []string{"d", "e"}
+	program.PausedUntil["d"] = timeToMillis(time.Now().Add(-time.Minute))
+	program.PendingRound = &PendingRound{RoundID: "r1", Confirmed: []string{"a", "b", "d", "e"}}
+
+	preview := p.previewRound(program)
+	assert.ElementsMatch([]string{"a", "b", "d"}, append(matchedUsers(preview.Groups), preview.OddUser))
+
+	program.PendingRound = nil
+	preview = p.previewRound(program)
+	assert.ElementsMatch([]string{"a", "b", "c", "d"}, matchedUsers(preview.Groups))
+}
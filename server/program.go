@@ -0,0 +1,244 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/pkg/errors"
+)
+
+// defaultProgram is the program used when no program is given, it can't be deleted
+const defaultProgram = "default"
+
+// programNameRegexp limits the program names so they fit in the KV keys and can be typed in the commands
+var programNameRegexp = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// errProgramNotFound is returned when updating a program that doesn't exist
+var errProgramNotFound = errors.New("the program doesn't exist")
+
+// Program is an independent group of users that meet on their own schedule
+type Program struct {
+	Name string `json:"name"`
+
+	// Schedule and InitText replace the plugin settings when they are set
+	Schedule string `json:"schedule"`
+	InitText string `json:"initText"`
+
+	// ChannelID is the channel the program is bound to, its members are the users of the program
+	ChannelID string `json:"channelId"`
+
+	Users       []string `json:"users"`
+	Paused      []string `json:"paused"`
+	History     History  `json:"history"`
+	OddUserTurn []string `json:"oddUserTurn"`
+
+	// the last round that ran and the user that sat it out
+	LastRoundID string `json:"lastRoundId"`
+	LastRoundAt int64  `json:"lastRoundAt"`
+	OddUser     string `json:"oddUser"`
+
+	// Frequencies are the meeting frequencies the users chose and LastPaired the last round every
+	// user met someone in
+	Frequencies map[string]string       `json:"frequencies"`
+	LastPaired  map[string]*PairedRound `json:"lastPaired"`
+
+	// PausedUntil is when the pause of the paused users ends, in milliseconds, and SkipRounds how
+	// many rounds the paused users skip. The users in neither are paused until they unpause.
+	PausedUntil map[string]int64 `json:"pausedUntil"`
+	SkipRounds  map[string]int   `json:"skipRounds"`
+
+	// PendingRound is the round waiting for the users to join it
+	PendingRound *PendingRound `json:"pendingRound"`
+
+	// Rounds are the participants the program had in every round, for the statistics
+	Rounds []*RoundSnapshot `json:"rounds"`
+}
+
+// NewProgram returns an empty program
+func NewProgram(name string) *Program {
+	return &Program{
+		Name:        name,
+		Users:       []string{},
+		Paused:      []string{},
+		History:     History{},
+		OddUserTurn: []string{},
+		Frequencies: map[string]string{},
+		LastPaired:  map[string]*PairedRound{},
+		PausedUntil: map[string]int64{},
+		SkipRounds:  map[string]int{},
+		Rounds:      []*RoundSnapshot{},
+	}
+}
+
+// Clone deep copies the program
+func (program *Program) Clone() *Program {
+	clone := *program
+	clone.Users = append([]string{}, program.Users...)
+	clone.Paused = append([]string{}, program.Paused...)
+	clone.History = make(History, 0, len(program.History))
+	clone.OddUserTurn = append([]string{}, program.OddUserTurn...)
+	clone.Frequencies = make(map[string]string, len(program.Frequencies))
+	clone.LastPaired = make(map[string]*PairedRound, len(program.LastPaired))
+	clone.PausedUntil = make(map[string]int64, len(program.PausedUntil))
+	clone.SkipRounds = make(map[string]int, len(program.SkipRounds))
+
+	for userID, frequency := range program.Frequencies {
+		clone.Frequencies[userID] = frequency
+	}
+
+	for userID, round := range program.LastPaired {
+		copied := *round
+		clone.LastPaired[userID] = &copied
+	}
+
+	for userID, until := range program.PausedUntil {
+		clone.PausedUntil[userID] = until
+	}
+
+	for userID, rounds := range program.SkipRounds {
+		clone.SkipRounds[userID] = rounds
+	}
+
+	clone.Rounds = make([]*RoundSnapshot, 0, len(program.Rounds))
+	for _, snapshot := range program.Rounds {
+		copied := *snapshot
+		clone.Rounds = append(clone.Rounds, &copied)
+	}
+
+	if program.PendingRound != nil {
+		pending := *program.PendingRound
+		pending.Invited = append([]string{}, program.PendingRound.Invited...)
+		pending.Confirmed = append([]string{}, program.PendingRound.Confirmed...)
+		clone.PendingRound = &pending
+	}
+
+	for _, record := range program.History {
+		copied := *record
+		copied.Participants = append([]string{}, record.Participants...)
+
+		if record.Feedback != nil {
+			copied.Feedback = make(map[string]*MeetingFeedback, len(record.Feedback))
+			for userID, feedback := range record.Feedback {
+				answer := *feedback
+				copied.Feedback[userID] = &answer
+			}
+		}
+		clone.History = append(clone.History, &copied)
+	}
+
+	return &clone
+}
+
+// IsPaused returns true if the user is paused at the time, a timed pause is over once its end
+// passed even if no round lifted it yet
+func (program *Program) IsPaused(userID string, at time.Time) bool {
+	if !utils.Contains(program.Paused, userID) {
+		return false
+	}
+
+	until, ok := program.PausedUntil[userID]
+	return !ok || until > timeToMillis(at)
+}
+
+// PausedUsers returns the users that are paused at the time
+func (program *Program) PausedUsers(at time.Time) []string {
+	users := []string{}
+
+	for _, userID := range program.Paused {
+		if program.IsPaused(userID, at) {
+			users = append(users, userID)
+		}
+	}
+
+	return users
+}
+
+// ActiveUsers returns the users that are not paused at the time
+func (program *Program) ActiveUsers(at time.Time) []string {
+	var users []string
+
+	for _, userID := range program.Users {
+		if !program.IsPaused(userID, at) {
+			users = append(users, userID)
+		}
+	}
+
+	return users
+}
+
+// validateProgramName checks that name can be used for a new program
+func validateProgramName(name string) error {
+	if !programNameRegexp.MatchString(name) {
+		return errors.New("program names can only have up to 32 lowercase letters, numbers, - and _")
+	}
+
+	return nil
+}
+
+// parseProgram takes the program out of the command arguments, any argument that is the name of
+// an existing program selects it. The default program is used if none is given.
+func parseProgram(state *State, args []string) (string, []string) {
+	program := defaultProgram
+	rest := []string{}
+	found := false
+
+	for _, arg := range args {
+		if _, ok := state.Programs[arg]; ok && !found {
+			program = arg
+			found = true
+			continue
+		}
+
+		rest = append(rest, arg)
+	}
+
+	return program, rest
+}
+
+// updateProgram changes the program, it fails if the program doesn't exist
+func (p *Plugin) updateProgram(name string, mutate func(program *Program) error) error {
+	return p.updateState(func(state *State) error {
+		program, ok := state.Programs[name]
+		if !ok {
+			return errProgramNotFound
+		}
+
+		return mutate(program)
+	})
+}
+
+// getProgram returns the current program, it must not be modified
+func (p *Plugin) getProgram(name string) (*Program, bool) {
+	program, ok := p.state.Get().Programs[name]
+	return program, ok
+}
+
+// getInitText returns the text posted when the users of the program meet
+func (p *Plugin) getInitText(program *Program) string {
+	if program.InitText != "" {
+		return program.InitText
+	}
+
+	return p.getConfiguration().InitText
+}
+
+// getCronSpecs returns the cron expressions of the program, the configured recurrence if it has no schedule
+func (p *Plugin) getCronSpecs(program *Program) []string {
+	if program.Schedule != "" {
+		return strings.Split(program.Schedule, ",")
+	}
+
+	config := p.getConfiguration()
+	configCron := config.Cron
+
+	if configCron == "" {
+		configCron = "@weekly"
+	}
+	if config.Cron == "custom" && len(config.CustomCron) > 0 {
+		configCron = config.CustomCron
+	}
+
+	return strings.Split(configCron, ",")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProgram(t *testing.T) {
+	assert := assert.New(t)
+	state := NewState()
+	state.Programs["sales"] = NewProgram("sales")
+
+	name, rest := parseProgram(state, []string{"@alice", "sales", "@bob"})
+	assert.Equal("sales", name)
+	assert.Equal([]string{"@alice", "@bob"}, rest)
+
+	name, rest = parseProgram(state, []string{"@alice"})
+	assert.Equal(defaultProgram, name)
+	assert.Equal([]string{"@alice"}, rest)
+
+	assert.Equal("", unknownProgramArg("add", []string{"@alice"}))
+	assert.Equal("engineering", unknownProgramArg("on", []string{"engineering"}))
+	assert.Equal("", unknownProgramArg("set_odd", []string{`["alice"]`}))
+}
+
+func TestProgramCommands(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	defer p.removeCron()
+
+	run := func(userID string, command string) string {
+		response, err := p.ExecuteCommand(nil, &model.CommandArgs{UserId: userID, Command: command})
+		assert.Nil(err)
+		return response.Text
+	}
+
+	assert.Equal("Only system admins can do this.", run("alice", "/gather-plugin program create sales"))
+	assert.Contains(run("admin", "/gather-plugin program create Sales!"), "Invalid program name")
+	assert.Contains(run("admin", "/gather-plugin program create sales"), "Program sales created")
+	assert.Contains(run("admin", "/gather-plugin program create sales"), "already exists")
+
+	assert.Contains(run("admin", "/gather-plugin program schedule sales 0 9 * * MON"), "`0 9 * * MON`")
+	assert.Contains(run("admin", "/gather-plugin program schedule sales every day"), "Invalid cron expression")
+	run("admin", "/gather-plugin program text sales Hi,\n  meet your colleague!")
+
+	run("alice", "/gather-plugin on sales")
+	run("bob", "/gather-plugin on")
+	assert.Equal("The program engineering doesn't exist.", run("bob", "/gather-plugin on engineering"))
+
+	state := p.state.Get()
+	assert.Equal([]string{"alice"}, state.Programs["sales"].Users)
+	assert.Equal([]string{"bob"}, state.Programs[defaultProgram].Users)
+	assert.Equal("0 9 * * MON", state.Programs["sales"].Schedule)
+	assert.Equal("Hi,\n  meet your colleague!", p.getInitText(state.Programs["sales"]))
+	assert.Equal([]string{"0 9 * * MON"}, p.getCronSpecs(state.Programs["sales"]))
+	assert.Len(p.cron.Entries(), 2)
+
+	assert.Equal("The default program can't be deleted.", run("admin", "/gather-plugin program delete default"))
+	assert.Equal("Program sales deleted.", run("admin", "/gather-plugin program delete sales"))
+	assert.Equal([]string{defaultProgram}, p.state.Get().ProgramNames())
+	assert.Len(p.cron.Entries(), 1)
+}
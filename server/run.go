@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// runActionPath is the API route of the buttons of the run confirmation
+const runActionPath = "actions/run"
+
+// sendRunConfirmation shows the admin the preview of the round with buttons to run or cancel it
+func (p *Plugin) sendRunConfirmation(args *model.CommandArgs, program *Program) {
+	text := p.previewText(program.Name, p.previewRound(program))
+	url := fmt.Sprintf("/plugins/%s%s%s", manifest.Id, apiPrefix, runActionPath)
+
+	post := &model.Post{
+		UserId:    p.botUserID,
+		ChannelId: args.ChannelId,
+	}
+
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{{
+		Title: fmt.Sprintf("Run a round of the program %s now?", program.Name),
+		Text:  text + "\nTies are broken randomly, so the round may pair users differently.",
+		Actions: []*model.PostAction{
+			{
+				Id:   "run",
+				Name: "Run",
+				Integration: &model.PostActionIntegration{
+					URL:     url,
+					Context: map[string]interface{}{"program": program.Name, "run": true},
+				},
+			},
+			{
+				Id:   "cancel",
+				Name: "Cancel",
+				Integration: &model.PostActionIntegration{
+					URL:     url,
+					Context: map[string]interface{}{"program": program.Name, "run": false},
+				},
+			},
+		},
+	}})
+
+	p.API.SendEphemeralPost(args.UserId, post)
+}
+
+// apiRunAction runs or cancels the round confirmed with the buttons of the run confirmation
+func (p *Plugin) apiRunAction(req *apiRequest) {
+	if req.r.Method != http.MethodPost {
+		writeError(req.w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	action := model.PostActionIntegrationRequest{}
+	if err := json.NewDecoder(req.r.Body).Decode(&action); err != nil {
+		writeError(req.w, http.StatusBadRequest, fmt.Sprintf("Failed parsing json: %s.", err.Error()))
+		return
+	}
+
+	name, _ := action.Context["program"].(string)
+	run, _ := action.Context["run"].(bool)
+	msg := "The round was cancelled."
+
+	if run {
+		records, err := p.runManualRound(name, req.userID)
+
+		if err == errRoundRunning {
+			msg = "A round of the program is already running."
+		} else if err == errProgramNotFound {
+			msg = fmt.Sprintf("The program %s doesn't exist.", name)
+		} else if err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to run the round of the program %s: %s", name, err.Error()))
+			msg = "Failed to run the round, contact your administrator."
+		} else {
+			msg = fmt.Sprintf("Round of the program %s started, meetings created: %d.", name, len(records))
+		}
+	}
+
+	// the buttons are replaced with the result so the round can't be run twice
+	p.API.UpdateEphemeralPost(req.userID, &model.Post{
+		Id:        action.PostId,
+		UserId:    p.botUserID,
+		ChannelId: action.ChannelId,
+		Message:   msg,
+	})
+
+	writeJSON(req.w, http.StatusOK, &model.PostActionIntegrationResponse{})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRunCommand(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	api := p.API.(*plugintest.API)
+
+	var confirmation *model.Post
+	api.On("SendEphemeralPost", "admin", mock.Anything).Run(func(args mock.Arguments) {
+		confirmation = args.Get(1).(*model.Post)
+	}).Return(nil)
+
+	var updated *model.Post
+	api.On("UpdateEphemeralPost", "admin", mock.Anything).Run(func(args mock.Arguments) {
+		updated = args.Get(1).(*model.Post)
+	}).Return(nil)
+
+	assert.Nil(p.updateProgram(defaultProgram, func(program *Program) error {
+		program.Users = []string{"alice", "bob"}
+		return nil
+	}))
+
+	response, _ := p.ExecuteCommand(nil, &model.CommandArgs{UserId: "alice", Command: "/gather-plugin run"})
+	assert.Equal("Only system admins can do this.", response.Text)
+
+	_, err := p.ExecuteCommand(nil, &model.CommandArgs{UserId: "admin", ChannelId: "channel", Command: "/gather-plugin run"})
+	assert.Nil(err)
+	assert.NotNil(confirmation)
+
+	attachments := confirmation.Attachments()
+	assert.Len(attachments, 1)
+	assert.Contains(attachments[0].Text, "@alice")
+	assert.Len(attachments[0].Actions, 2)
+
+	click := func(userID string, action *model.PostAction) int {
+		request := &model.PostActionIntegrationRequest{UserId: userID, PostId: "post", Context: action.Integration.Context}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/"+runActionPath, bytes.NewReader(request.ToJson()))
+		r.Header.Set("Mattermost-User-Id", userID)
+		p.ServeHTTP(nil, w, r)
+		return w.Code
+	}
+
+	assert.Equal(http.StatusForbidden, click("alice", attachments[0].Actions[0]))
+
+	assert.Equal(http.StatusOK, click("admin", attachments[0].Actions[1]))
+	assert.Equal("The round was cancelled.", updated.Message)
+	assert.Empty(p.state.Get().Programs[defaultProgram].History)
+
+	assert.Equal(http.StatusOK, click("admin", attachments[0].Actions[0]))
+	assert.Contains(updated.Message, "meetings created: 1")
+
+	history := p.state.Get().Programs[defaultProgram].History
+	assert.Len(history, 1)
+	assert.True(strings.HasPrefix(history[0].RoundID, "manual-"))
+
+	// a round without meetings still records who ran it
+	assert.Nil(p.updateProgram(defaultProgram, func(program *Program) error {
+		program.Users = []string{"alice"}
+		return nil
+	}))
+	assert.Equal(http.StatusOK, click("admin", attachments[0].Actions[0]))
+	assert.Contains(updated.Message, "meetings created: 0")
+
+	rounds := p.state.Get().Programs[defaultProgram].Rounds
+	assert.Len(rounds, 2)
+	assert.Equal(history[0].RoundID, rounds[0].RoundID)
+	assert.Equal("admin", rounds[0].TriggeredBy)
+	assert.Equal("admin", rounds[1].TriggeredBy)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// State is all the data the plugin persists, it's saved as a single value so it's always consistent
+type State struct {
+	Programs map[string]*Program `json:"programs"`
+
+	// Blocks are the pairs that never meet, in any program
+	Blocks []*BlockRule `json:"blocks"`
+
+	// Tags are the groups the admins set to every user to mix them
+	Tags map[string][]string `json:"tags"`
+}
+
+// NewState returns a state with an empty default program
+func NewState() *State {
+	return &State{
+		Programs: map[string]*Program{
+			defaultProgram: NewProgram(defaultProgram),
+		},
+		Blocks: []*BlockRule{},
+		Tags:   map[string][]string{},
+	}
+}
+
+// Clone deep copies the state
+func (s *State) Clone() *State {
+	clone := &State{
+		Programs: make(map[string]*Program, len(s.Programs)),
+		Blocks:   make([]*BlockRule, 0, len(s.Blocks)),
+		Tags:     make(map[string][]string, len(s.Tags)),
+	}
+
+	for userID, tags := range s.Tags {
+		clone.Tags[userID] = append([]string{}, tags...)
+	}
+
+	for name, program := range s.Programs {
+		clone.Programs[name] = program.Clone()
+	}
+
+	for _, rule := range s.Blocks {
+		copied := *rule
+		copied.Users = append([]string{}, rule.Users...)
+		clone.Blocks = append(clone.Blocks, &copied)
+	}
+
+	return clone
+}
+
+// ProgramNames returns the names of the programs sorted alphabetically
+func (s *State) ProgramNames() []string {
+	names := []string{}
+
+	for name := range s.Programs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// stateManager guards the in-memory state shared by the hooks, the commands and the cron jobs.
+// The states it returns are never modified, every change is made on a copy that replaces the
+// current state once it's saved.
+type stateManager struct {
+	lock  sync.Mutex
+	store Store
+	state *State
+}
+
+func newStateManager(store Store, state *State) *stateManager {
+	return &stateManager{
+		store: store,
+		state: state,
+	}
+}
+
+// Get returns the current state, it must not be modified
+func (m *stateManager) Get() *State {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.state
+}
+
+// Reload replaces the current state with the stored one, e.g. another server of the cluster saved it
+func (m *stateManager) Reload() (*State, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	state, err := m.store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	m.state = state
+
+	return state, nil
+}
+
+// Update applies the mutation to a copy of the state and saves it. If the stored state changed
+// meanwhile the state is reloaded and the mutation applied again, so it must not have side effects.
+func (m *stateManager) Update(mutate func(state *State) error) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for attempt := 0; attempt < 5; attempt++ {
+		state := m.state.Clone()
+
+		if err := mutate(state); err != nil {
+			return err
+		}
+
+		err := m.store.Save(state)
+		if err == nil {
+			m.state = state
+			return nil
+		}
+
+		if err != errStateConflict {
+			return errors.Wrap(err, "failed to persist the state")
+		}
+
+		latest, err := m.store.Load()
+		if err != nil {
+			return errors.Wrap(err, "failed to reload the state")
+		}
+
+		m.state = latest
+	}
+
+	return errStateConflict
+}
+
+// updateState changes the state, logging the errors
+func (p *Plugin) updateState(mutate func(state *State) error) error {
+	err := p.state.Update(mutate)
+
+	// the missing programs and rules are reported to the user, they are not failures
+	if err != nil && err != errProgramNotFound && err != errBlockNotFound {
+		p.API.LogError(fmt.Sprintf("Failed to update the state: %s", err.Error()))
+	}
+
+	return err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUpdateStateRetriesOnConflict(t *testing.T) {
+	assert := assert.New(t)
+	kv := newMemoryKV()
+	p := &Plugin{state: newStateManager(newMemoryStore(kv), NewState())}
+	other := &Plugin{state: newStateManager(newMemoryStore(kv), NewState())}
+
+	assert.Nil(p.updateState(func(state *State) error {
+		state.Programs[defaultProgram].Users = append(state.Programs[defaultProgram].Users, "a")
+		return nil
+	}))
+
+	// another server saves the state meanwhile
+	_, err := other.state.Reload()
+	assert.Nil(err)
+	assert.Nil(other.updateState(func(state *State) error {
+		state.Programs[defaultProgram].Users = append(state.Programs[defaultProgram].Users, "b")
+		return nil
+	}))
+
+	attempts := 0
+	assert.Nil(p.updateState(func(state *State) error {
+		attempts++
+		state.Programs[defaultProgram].Users = append(state.Programs[defaultProgram].Users, "c")
+		return nil
+	}))
+
+	assert.Equal(2, attempts)
+	assert.Equal([]string{"a", "b", "c"}, p.state.Get().Programs[defaultProgram].Users)
+
+	saved, _ := newMemoryStore(kv).Load()
+	assert.Equal([]string{"a", "b", "c"}, saved.Programs[defaultProgram].Users)
+}
+
+func TestStateClone(t *testing.T) {
+	assert := assert.New(t)
+	state := NewState()
+	program := state.Programs[defaultProgram]
+	program.Users = []string{"a", "b"}
+	program.History = History{{RoundID: "1", Participants: []string{"a", "b"}}}
+
+	clone := state.Clone()
+	clone.Programs[defaultProgram].Users[0] = "c"
+	clone.Programs[defaultProgram].History[0].Participants[0] = "c"
+	clone.Programs["sales"] = NewProgram("sales")
+
+	assert.Equal([]string{"a", "b"}, program.Users)
+	assert.Equal([]string{"a", "b"}, program.History[0].Participants)
+	assert.Equal([]string{defaultProgram}, state.ProgramNames())
+}
+
+func newTestPlugin(kv *memoryKV) *Plugin {
+	api := &plugintest.API{}
+
+	api.On("GetUser", mock.AnythingOfType("string")).Return(func(userID string) *model.User {
+		roles := model.SYSTEM_USER_ROLE_ID
+		if userID == "admin" {
+			roles += " " + model.SYSTEM_ADMIN_ROLE_ID
+		}
+
+		return &model.User{Id: userID, Username: userID, Roles: roles}
+	}, nil)
+	api.On("GetGroupChannel", mock.Anything).Return(&model.Channel{Id: "channel"}, nil)
+	api.On("GetDirectChannel", mock.Anything, mock.AnythingOfType("string")).Return(func(botUserID, userID string) *model.Channel {
+		return &model.Channel{Id: "dm_" + userID}
+	}, nil)
+	api.On("CreatePost", mock.Anything).Return(&model.Post{Id: "post"}, nil)
+	api.On("LogError", mock.Anything).Maybe()
+	api.On("LogInfo", mock.Anything).Maybe()
+
+	// the round lease is kept next to the state
+	api.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) []byte {
+		value, _ := kv.KVGet(key)
+		return value
+	}, nil)
+	api.On("KVCompareAndSet", mock.AnythingOfType("string"), mock.Anything, mock.Anything).Return(func(key string, oldValue, newValue []byte) bool {
+		ok, _ := kv.KVCompareAndSet(key, oldValue, newValue)
+		return ok
+	}, nil)
+	api.On("KVCompareAndDelete", mock.AnythingOfType("string"), mock.Anything).Return(func(key string, oldValue []byte) bool {
+		ok, _ := kv.KVCompareAndDelete(key, oldValue)
+		return ok
+	}, nil)
+
+	p := &Plugin{state: newStateManager(newMemoryStore(kv), NewState())}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{FirstMeeting: true})
+
+	return p
+}
+
+func TestCommandsDuringRound(t *testing.T) {
+	assert := assert.New(t)
+	kv := newMemoryKV()
+	p := newTestPlugin(kv)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(p.addUser(defaultProgram, fmt.Sprintf("user%d", i)))
+	}
+	assert.Nil(p.addUser(defaultProgram, "leaver"))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		userID := fmt.Sprintf("user%d", i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for _, command := range []string{"on", "pause", "pause", "off", "on"} {
+				_, err := p.ExecuteCommand(nil, &model.CommandArgs{UserId: userID, Command: "/gather-plugin " + command})
+				assert.Nil(err)
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(round int) {
+			defer wg.Done()
+			p.runMeetings(defaultProgram, fmt.Sprintf("round%d", round), time.Now(), "")
+		}(i)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.UserHasLeftTeam(nil, &model.TeamMember{UserId: "leaver"})
+	}()
+
+	wg.Wait()
+
+	state := p.state.Get()
+	program := state.Programs[defaultProgram]
+	assert.Len(program.Users, 20)
+	assert.Empty(program.Paused)
+
+	for _, userID := range program.OddUserTurn {
+		assert.Contains(program.Users, userID)
+	}
+
+	saved, err := newMemoryStore(kv).Load()
+	assert.Nil(err)
+	assert.Equal(state, saved)
+}
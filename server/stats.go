@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+)
+
+// statsTableRounds is how many of the last rounds the stats command shows
+const statsTableRounds = 10
+
+// RoundSnapshot is the number of participants of a program when a round ran, TriggeredBy is the
+// admin that ran the round by hand and it's empty for the scheduled rounds
+type RoundSnapshot struct {
+	RoundID     string `json:"roundId"`
+	Timestamp   int64  `json:"timestamp"`
+	Total       int    `json:"total"`
+	Paused      int    `json:"paused"`
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+}
+
+// ParticipantStats counts the users of a program
+type ParticipantStats struct {
+	Total  int `json:"total"`
+	Active int `json:"active"`
+	Paused int `json:"paused"`
+}
+
+// RoundStats are the meetings of a round and, for the rounds that ran after the statistics were
+// recorded, the participants the program had
+type RoundStats struct {
+	RoundID      string            `json:"round_id"`
+	Timestamp    int64             `json:"timestamp"`
+	Participants *ParticipantStats `json:"participants,omitempty"`
+	Meetings     int               `json:"meetings"`
+	RepeatPairs  int               `json:"repeat_pairs"`
+	TriggeredBy  string            `json:"triggered_by,omitempty"`
+}
+
+// ProgramStats are the statistics of a program. The repeat pair rate is the fraction of the pairs
+// that had already met and the completion rate the fraction of the meetings with feedback that happened.
+type ProgramStats struct {
+	Participants   ParticipantStats `json:"participants"`
+	Rounds         []*RoundStats    `json:"rounds"`
+	Meetings       int              `json:"meetings"`
+	RepeatPairRate float64          `json:"repeat_pair_rate"`
+	Answered       int              `json:"answered"`
+	CompletionRate float64          `json:"completion_rate"`
+	AverageRating  float64          `json:"average_rating"`
+	NeverMatched   []string         `json:"never_matched"`
+}
+
+// newRoundSnapshot counts the participants of the program
+func newRoundSnapshot(program *Program, roundID string, at time.Time, triggeredBy string) *RoundSnapshot {
+	return &RoundSnapshot{
+		RoundID:     roundID,
+		Timestamp:   timeToMillis(at),
+		Total:       len(program.Users),
+		Paused:      len(utils.Intersect(program.PausedUsers(at), program.Users)),
+		TriggeredBy: triggeredBy,
+	}
+}
+
+// computeStats computes the statistics of the program from its history, the participants are
+// counted at the time
+func computeStats(program *Program, at time.Time) *ProgramStats {
+	paused := len(utils.Intersect(program.PausedUsers(at), program.Users))
+	stats := &ProgramStats{
+		Participants: ParticipantStats{Total: len(program.Users), Active: len(program.Users) - paused, Paused: paused},
+		Rounds:       []*RoundStats{},
+		Meetings:     len(program.History),
+		NeverMatched: []string{},
+	}
+
+	rounds := map[string]*RoundStats{}
+	met := map[string]bool{}
+	matched := map[string]bool{}
+	pairs, repeats, happened, ratings, rated := 0, 0, 0, 0, 0
+
+	for _, record := range program.History {
+		round, ok := rounds[record.RoundID]
+		if !ok {
+			round = &RoundStats{RoundID: record.RoundID, Timestamp: record.Timestamp}
+			rounds[record.RoundID] = round
+			stats.Rounds = append(stats.Rounds, round)
+		}
+
+		round.Meetings++
+
+		for i, userID := range record.Participants {
+			matched[userID] = true
+
+			for _, pairUserID := range record.Participants[i+1:] {
+				key := pairKey(userID, pairUserID)
+				if met[key] {
+					round.RepeatPairs++
+					repeats++
+				}
+
+				met[key] = true
+				pairs++
+			}
+		}
+
+		if len(record.Feedback) > 0 {
+			stats.Answered++
+			if !record.notHappened() {
+				happened++
+			}
+		}
+
+		for _, feedback := range record.Feedback {
+			if feedback.Rating > 0 {
+				ratings += feedback.Rating
+				rated++
+			}
+		}
+	}
+
+	for _, snapshot := range program.Rounds {
+		if round, ok := rounds[snapshot.RoundID]; ok {
+			round.Participants = &ParticipantStats{Total: snapshot.Total, Active: snapshot.Total - snapshot.Paused, Paused: snapshot.Paused}
+			round.TriggeredBy = snapshot.TriggeredBy
+		}
+	}
+
+	if pairs > 0 {
+		stats.RepeatPairRate = float64(repeats) / float64(pairs)
+	}
+
+	if stats.Answered > 0 {
+		stats.CompletionRate = float64(happened) / float64(stats.Answered)
+	}
+
+	if rated > 0 {
+		stats.AverageRating = float64(ratings) / float64(rated)
+	}
+
+	for _, userID := range program.Users {
+		if !matched[userID] {
+			stats.NeverMatched = append(stats.NeverMatched, userID)
+		}
+	}
+
+	return stats
+}
+
+// pairKey identifies the pair of users in any order
+func pairKey(userID string, pairUserID string) string {
+	users := []string{userID, pairUserID}
+	sort.Strings(users)
+
+	return strings.Join(users, ",")
+}
+
+// statsText formats the statistics of the program with a table of the last rounds
+func (p *Plugin) statsText(name string, stats *ProgramStats) string {
+	var builder strings.Builder
+
+	if name == defaultProgram {
+		builder.WriteString("Statistics of the coffee meetings:\n")
+	} else {
+		builder.WriteString(fmt.Sprintf("Statistics of the program %s:\n", name))
+	}
+
+	builder.WriteString(fmt.Sprintf("- Participants: %d (%d active, %d paused)\n", stats.Participants.Total, stats.Participants.Active, stats.Participants.Paused))
+	builder.WriteString(fmt.Sprintf("- Meetings: %d in %d rounds\n", stats.Meetings, len(stats.Rounds)))
+	builder.WriteString(fmt.Sprintf("- Repeat pair rate: %.1f%%\n", stats.RepeatPairRate*100))
+
+	if stats.Answered > 0 {
+		builder.WriteString(fmt.Sprintf("- Completion rate: %.1f%% of %d meetings with feedback", stats.CompletionRate*100, stats.Answered))
+		if stats.AverageRating > 0 {
+			builder.WriteString(fmt.Sprintf(", average rating %.1f", stats.AverageRating))
+		}
+		builder.WriteString("\n")
+	} else {
+		builder.WriteString("- Completion rate: no feedback yet\n")
+	}
+
+	if len(stats.NeverMatched) > 0 {
+		builder.WriteString(fmt.Sprintf("- Never matched: %s\n", p.usernamesText(stats.NeverMatched)))
+	}
+
+	rounds := stats.Rounds
+	if len(rounds) == 0 {
+		return builder.String()
+	}
+
+	if len(rounds) > statsTableRounds {
+		rounds = rounds[len(rounds)-statsTableRounds:]
+	}
+
+	builder.WriteString("\n| Round | Date | Participants | Active | Paused | Meetings | Repeat pairs |\n")
+	builder.WriteString("|---|---|---|---|---|---|---|\n")
+
+	for _, round := range rounds {
+		roundID, date := round.RoundID, "-"
+		if roundID == "" {
+			// the meetings started on sign in before the first round
+			roundID = "-"
+		}
+
+		if round.RoundID != legacyRoundID {
+			date = time.Unix(0, round.Timestamp*int64(time.Millisecond)).UTC().Format("2006-01-02")
+		}
+
+		participants := "- | - | -"
+		if round.Participants != nil {
+			participants = fmt.Sprintf("%d | %d | %d", round.Participants.Total, round.Participants.Active, round.Participants.Paused)
+		}
+
+		builder.WriteString(fmt.Sprintf("| %s | %s | %s | %d | %d |\n", roundID, date, participants, round.Meetings, round.RepeatPairs))
+	}
+
+	return builder.String()
+}
+
+// apiGetStats returns the statistics of the program
+func (p *Plugin) apiGetStats(req *apiRequest, program *Program) {
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	writeJSON(req.w, http.StatusOK, computeStats(program, time.Now()))
+}
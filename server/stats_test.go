@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	assert := assert.New(t)
+
+	program := NewProgram(defaultProgram)
+	program.Users = []string{"alice", "bob", "carol", "dave", "erin"}
+	program.Paused = []string{"erin"}
+	program.History = History{
+		{RoundID: legacyRoundID, Participants: []string{"alice", "bob"}},
+		{RoundID: "r1", Timestamp: 10, Participants: []string{"alice", "carol"}, Feedback: map[string]*MeetingFeedback{"alice": {Status: feedbackMet, Rating: 5}}},
+		{RoundID: "r1", Timestamp: 10, Participants: []string{"bob", "dave"}, Feedback: map[string]*MeetingFeedback{"bob": {Status: feedbackNotMet}}},
+		{RoundID: "r2", Timestamp: 20, Participants: []string{"alice", "bob"}, Feedback: map[string]*MeetingFeedback{"bob": {Status: feedbackMet, Rating: 3}}},
+	}
+	program.Rounds = []*RoundSnapshot{{RoundID: "r2", Timestamp: 20, Total: 5, Paused: 1}}
+
+	stats := computeStats(program, time.Now())
+	assert.Equal(ParticipantStats{Total: 5, Active: 4, Paused: 1}, stats.Participants)
+	assert.Equal(4, stats.Meetings)
+	assert.Len(stats.Rounds, 3)
+	assert.Nil(stats.Rounds[1].Participants)
+	assert.Equal(&RoundStats{RoundID: "r2", Timestamp: 20, Participants: &ParticipantStats{Total: 5, Active: 4, Paused: 1}, Meetings: 1, RepeatPairs: 1}, stats.Rounds[2])
+	assert.Equal(0.25, stats.RepeatPairRate)
+	assert.Equal(3, stats.Answered)
+	assert.InDelta(2.0/3, stats.CompletionRate, 0.001)
+	assert.Equal(4.0, stats.AverageRating)
+	assert.Equal([]string{"erin"}, stats.NeverMatched)
+}
+
+func TestStatsCommand(t *testing.T) {
+	assert := assert.New(t)
+	p := newTestPlugin(newMemoryKV())
+	p.setConfiguration(&configuration{})
+
+	run := func(userID string, command string) string {
+		response, err := p.ExecuteCommand(nil, &model.CommandArgs{UserId: userID, Command: command})
+		assert.Nil(err)
+		return response.Text
+	}
+
+	run("alice", "/gather-plugin on")
+	run("bob", "/gather-plugin on")
+	run("carol", "/gather-plugin on")
+	run("carol", "/gather-plugin pause")
+
+	assert.Equal("Only system admins can do this.", run("alice", "/gather-plugin stats"))
+	assert.Contains(run("admin", "/gather-plugin stats"), "- Never matched: @alice, @bob and @carol")
+
+	records := p.runMeetings(defaultProgram, "r1", time.Now(), "")
+	assert.Len(records, 1)
+
+	text := run("admin", "/gather-plugin stats")
+	assert.Contains(text, "- Participants: 3 (2 active, 1 paused)")
+	assert.Contains(text, "- Meetings: 1 in 1 rounds")
+	assert.Contains(text, "- Completion rate: no feedback yet")
+	assert.Contains(text, "| r1 | "+time.Now().UTC().Format("2006-01-02")+" | 3 | 2 | 1 | 1 | 0 |")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// stateKey stores the whole plugin state
+const stateKey = "state"
+
+// errStateConflict is returned by Store.Save when the state changed since it was loaded
+var errStateConflict = errors.New("the state was modified concurrently")
+
+// Store loads and saves the state. Save only succeeds if the stored state is the one the store
+// loaded or saved last, otherwise it returns errStateConflict and the state must be loaded again.
+type Store interface {
+	Load() (*State, error)
+	Save(state *State) error
+}
+
+// kvStore is the Store backed by the plugin KV store
+type kvStore struct {
+	api  kvCompareAndSetAPI
+	lock sync.Mutex
+	last []byte
+}
+
+// kvCompareAndSetAPI is the part of the plugin API the kvStore needs
+type kvCompareAndSetAPI interface {
+	KVGet(key string) ([]byte, *model.AppError)
+	KVCompareAndSet(key string, oldValue, newValue []byte) (bool, *model.AppError)
+}
+
+func newKVStore(api kvCompareAndSetAPI) *kvStore {
+	return &kvStore{api: api}
+}
+
+// Load implements Store
+func (s *kvStore) Load() (*State, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, appErr := s.api.KVGet(stateKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	state, err := decodeState(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.last = data
+
+	return state, nil
+}
+
+// Save implements Store
+func (s *kvStore) Save(state *State) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize the state")
+	}
+
+	ok, appErr := s.api.KVCompareAndSet(stateKey, s.last, data)
+	if appErr != nil {
+		return appErr
+	}
+
+	if !ok {
+		return errStateConflict
+	}
+
+	s.last = data
+
+	return nil
+}
+
+// memoryKV is an in-memory key value store, it backs the Store used in tests
+type memoryKV struct {
+	lock   sync.Mutex
+	values map[string][]byte
+}
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{values: make(map[string][]byte)}
+}
+
+// newMemoryStore returns a Store that keeps the state in memory, stores sharing kv behave like
+// different servers of a cluster
+func newMemoryStore(kv *memoryKV) *kvStore {
+	return newKVStore(kv)
+}
+
+func (kv *memoryKV) KVGet(key string) ([]byte, *model.AppError) {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	return kv.values[key], nil
+}
+
+func (kv *memoryKV) KVSet(key string, value []byte) *model.AppError {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	kv.values[key] = value
+
+	return nil
+}
+
+func (kv *memoryKV) KVCompareAndSet(key string, oldValue, newValue []byte) (bool, *model.AppError) {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	current, ok := kv.values[key]
+	if (oldValue == nil && ok) || (oldValue != nil && !bytes.Equal(current, oldValue)) {
+		return false, nil
+	}
+
+	kv.values[key] = newValue
+
+	return true, nil
+}
+
+func (kv *memoryKV) KVCompareAndDelete(key string, oldValue []byte) (bool, *model.AppError) {
+	kv.lock.Lock()
+	defer kv.lock.Unlock()
+
+	if !bytes.Equal(kv.values[key], oldValue) {
+		return false, nil
+	}
+
+	delete(kv.values, key)
+
+	return true, nil
+}
+
+func decodeState(data []byte) (*State, error) {
+	state := NewState()
+
+	if data == nil {
+		return state, nil
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, errors.Wrap(err, "failed to decode the state")
+	}
+
+	return state, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreCompareAndSet(t *testing.T) {
+	assert := assert.New(t)
+	kv := newMemoryKV()
+	store := newMemoryStore(kv)
+	other := newMemoryStore(kv)
+
+	state, err := store.Load()
+	assert.Nil(err)
+	assert.Empty(state.Programs[defaultProgram].Users)
+
+	_, err = other.Load()
+	assert.Nil(err)
+
+	state.Programs[defaultProgram].Users = []string{"a"}
+	assert.Nil(store.Save(state))
+
+	// saving on top of our own save is fine
+	state.Programs[defaultProgram].Paused = []string{"a"}
+	assert.Nil(store.Save(state))
+
+	// other loaded the state before the saves
+	assert.Equal(errStateConflict, other.Save(NewState()))
+
+	loaded, err := other.Load()
+	assert.Nil(err)
+	assert.Equal([]string{"a"}, loaded.Programs[defaultProgram].Users)
+	assert.Equal([]string{"a"}, loaded.Programs[defaultProgram].Paused)
+}
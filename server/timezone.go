@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const minutesPerDay = 24 * 60
+
+// workingWindow returns the start in minutes of the UTC day and the length in minutes of the
+// working hours every user shares, given their UTC offsets in minutes
+func workingWindow(offsets []int, start int, end int) (int, int) {
+	if len(offsets) == 0 {
+		return 0, 0
+	}
+
+	from, to := start-offsets[0], end-offsets[0]
+
+	for _, offset := range offsets[1:] {
+		bestFrom, bestTo := 0, 0
+
+		// the working hours may cross the UTC midnight, so try them the day before and after
+		for _, shift := range []int{-minutesPerDay, 0, minutesPerDay} {
+			windowFrom, windowTo := from, to
+			if start-offset+shift > windowFrom {
+				windowFrom = start - offset + shift
+			}
+			if end-offset+shift < windowTo {
+				windowTo = end - offset + shift
+			}
+
+			if windowTo-windowFrom > bestTo-bestFrom {
+				bestFrom, bestTo = windowFrom, windowTo
+			}
+		}
+
+		if bestTo <= bestFrom {
+			return 0, 0
+		}
+
+		from, to = bestFrom, bestTo
+	}
+
+	return ((from % minutesPerDay) + minutesPerDay) % minutesPerDay, to - from
+}
+
+// overlaps returns true if the working hours of the users overlap long enough, the users without a
+// timezone overlap with everyone
+func (input MatchInput) overlaps(userID string, pairUserID string) bool {
+	if input.MinOverlap == 0 {
+		return true
+	}
+
+	userOffset, ok := input.Offsets[userID]
+	pairOffset, pairOk := input.Offsets[pairUserID]
+	if !ok || !pairOk {
+		return true
+	}
+
+	_, length := workingWindow([]int{userOffset, pairOffset}, input.WorkingHours[0], input.WorkingHours[1])
+
+	return length >= input.MinOverlap
+}
+
+// overlappingFirst sorts the candidates to meet the user so the ones with overlapping working hours go first
+func (input MatchInput) overlappingFirst(userID string, candidates []string) {
+	if input.MinOverlap == 0 {
+		return
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return input.overlaps(userID, candidates[i]) && !input.overlaps(userID, candidates[j])
+	})
+}
+
+// overlappingUsers returns the users whose working hours overlap long enough with the ones of the user
+func (input MatchInput) overlappingUsers(userID string, users []string) []string {
+	overlapping := []string{}
+
+	for _, pairUserID := range users {
+		if input.overlaps(userID, pairUserID) {
+			overlapping = append(overlapping, pairUserID)
+		}
+	}
+
+	return overlapping
+}
+
+// getUserLocations returns the timezone of the users, the users without a timezone are left out
+func (p *Plugin) getUserLocations(users []string) map[string]*time.Location {
+	locations := make(map[string]*time.Location)
+
+	for _, userID := range users {
+		user, err := p.API.GetUser(userID)
+		if err != nil {
+			p.API.LogWarn(fmt.Sprintf("Failed to get the timezone of %s: %s", userID, err.Error()))
+			continue
+		}
+
+		timezone := user.GetPreferredTimezone()
+		if timezone == "" {
+			continue
+		}
+
+		location, locationErr := time.LoadLocation(timezone)
+		if locationErr != nil {
+			p.API.LogWarn(fmt.Sprintf("Failed to load the timezone %s of %s: %s", timezone, userID, locationErr.Error()))
+			continue
+		}
+
+		locations[userID] = location
+	}
+
+	return locations
+}
+
+// getUserOffsets returns the UTC offsets in minutes of the users with a timezone at the time
+func (p *Plugin) getUserOffsets(users []string, at time.Time) map[string]int {
+	offsets := make(map[string]int)
+
+	for userID, location := range p.getUserLocations(users) {
+		_, offset := at.In(location).Zone()
+		offsets[userID] = offset / 60
+	}
+
+	return offsets
+}
+
+// overlapText tells the users of a meeting when their working hours overlap in the local time of
+// every user, it's empty if the timezones are ignored or some user has no timezone
+func (p *Plugin) overlapText(users []string, at time.Time) string {
+	config := p.getConfiguration()
+	if config.getMinimumOverlap() == 0 {
+		return ""
+	}
+
+	locations := p.getUserLocations(users)
+	if len(locations) != len(users) {
+		return ""
+	}
+
+	offsets := []int{}
+	for _, userID := range users {
+		_, offset := at.In(locations[userID]).Zone()
+		offsets = append(offsets, offset/60)
+	}
+
+	start, end := config.getWorkingHours()
+	from, length := workingWindow(offsets, start, end)
+	if length == 0 {
+		return "Your working hours don't overlap, agree on a time that works for everyone."
+	}
+
+	day := at.UTC().Truncate(24 * time.Hour)
+	windowStart := day.Add(time.Duration(from) * time.Minute)
+	windowEnd := windowStart.Add(time.Duration(length) * time.Minute)
+
+	windows := []string{}
+	for _, userID := range users {
+		location := locations[userID]
+		windows = append(windows, fmt.Sprintf("%s-%s for %s (%s)", windowStart.In(location).Format("15:04"), windowEnd.In(location).Format("15:04"), p.usernamesText([]string{userID}), location.String()))
+	}
+
+	return fmt.Sprintf("Your working hours overlap %s: %s.", formatMinutes(length), strings.Join(windows, ", "))
+}
+
+// formatMinutes formats a duration in minutes like 1h30m
+func formatMinutes(minutes int) string {
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+
+	return fmt.Sprintf("%dh%dm", minutes/60, minutes%60)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWorkingWindow(t *testing.T) {
+	assert := assert.New(t)
+	start, end := 9*60, 17*60
+
+	from, length := workingWindow([]int{0, 60}, start, end)
+	assert.Equal(9*60, from)
+	assert.Equal(7*60, length)
+
+	// UTC-8 and UTC+9 overlap from 00:00 to 01:00 UTC
+	from, length = workingWindow([]int{-8 * 60, 9 * 60}, start, end)
+	assert.Equal(0, from)
+	assert.Equal(60, length)
+
+	_, length = workingWindow([]int{-8 * 60, 9 * 60, 60}, start, end)
+	assert.Equal(0, length)
+}
+
+func TestMatchersPreferOverlappingHours(t *testing.T) {
+	assert := assert.New(t)
+
+	// a and b are in UTC-8, c and d in UTC+9 and they already met the users of their timezone
+	input := MatchInput{
+		Users:        []string{"a", "b", "c", "d"},
+		Meetings:     map[string][]string{"a": {"b"}, "b": {"a"}, "c": {"d"}, "d": {"c"}},
+		Offsets:      map[string]int{"a": -8 * 60, "b": -8 * 60, "c": 9 * 60, "d": 9 * 60},
+		WorkingHours: [2]int{9 * 60, 17 * 60},
+		MinOverlap:   2 * 60,
+	}
+
+	for _, mode := range []string{"greedy", "optimal"} {
+		for i := 0; i < 20; i++ {
+			result := newMatcher(mode).Match(input)
+			assert.Len(result.Groups, 2)
+
+			for _, group := range result.Groups {
+				assert.True(input.overlaps(group[0], group[1]), "%s paired %v", mode, group)
+			}
+		}
+	}
+
+	// without the minimum overlap the timezones don't matter
+	input.MinOverlap = 0
+	result := newMatcher("optimal").Match(input)
+	for _, group := range result.Groups {
+		assert.NotEqual(input.Offsets[group[0]], input.Offsets[group[1]])
+	}
+}
+
+func TestOverlapText(t *testing.T) {
+	assert := assert.New(t)
+	timezones := map[string]string{"alice": "Europe/Madrid", "bob": "America/New_York"}
+
+	api := &plugintest.API{}
+	api.On("GetUser", mock.AnythingOfType("string")).Return(func(userID string) *model.User {
+		user := &model.User{Id: userID, Username: userID, Timezone: model.StringMap{}}
+		user.Timezone["useAutomaticTimezone"] = "false"
+		user.Timezone["manualTimezone"] = timezones[userID]
+		return user
+	}, nil)
+
+	p := &Plugin{}
+	p.SetAPI(api)
+	p.setConfiguration(&configuration{WorkingHours: "09:00-17:00"})
+
+	at := time.Date(2020, time.July, 1, 12, 0, 0, 0, time.UTC)
+	assert.Empty(p.overlapText([]string{"alice", "bob"}, at))
+
+	p.setConfiguration(&configuration{WorkingHours: "09:00-17:00", MinimumOverlap: "1"})
+	assert.Equal("Your working hours overlap 2h: 15:00-17:00 for @alice (Europe/Madrid), 09:00-11:00 for @bob (America/New_York).", p.overlapText([]string{"alice", "bob"}, at))
+
+	// carol has no timezone
+	assert.Empty(p.overlapText([]string{"alice", "carol"}, at))
+}
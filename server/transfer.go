@@ -0,0 +1,658 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/juanfran/mattermost-gather-users/server/utils"
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+// Formats of the exports and imports
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// Import modes, merge adds the imported data to the programs and replace overwrites their users,
+// pauses, history and odd user turn
+const (
+	importMerge   = "merge"
+	importReplace = "replace"
+)
+
+// exportVersion is the version of the export format
+const exportVersion = 1
+
+// csvHeader are the columns of the CSV exports. Every row is a user, a paused user, a user of the
+// odd user turn, in order, or a meeting. The timestamp of a paused user is when the pause ends and
+// the rounds how many rounds they skip.
+var csvHeader = []string{"type", "program", "round_id", "timestamp", "usernames", "rounds"}
+
+// ExportState is the state of the plugin with usernames instead of user ids, so it can be imported
+// in another server
+type ExportState struct {
+	Version  int              `json:"version"`
+	Programs []*ExportProgram `json:"programs"`
+}
+
+// ExportProgram is a program of the export
+type ExportProgram struct {
+	Name        string           `json:"name"`
+	Users       []string         `json:"users"`
+	Paused      []*ExportPause   `json:"paused"`
+	OddUserTurn []string         `json:"odd_user_turn"`
+	History     []*ExportMeeting `json:"history"`
+}
+
+// ExportPause is a paused user, until is when the pause ends and rounds how many rounds the user skips
+type ExportPause struct {
+	Username string `json:"username"`
+	Until    int64  `json:"until,omitempty"`
+	Rounds   int    `json:"rounds,omitempty"`
+}
+
+// ExportMeeting is a meeting of the history
+type ExportMeeting struct {
+	RoundID      string   `json:"round_id"`
+	Timestamp    int64    `json:"timestamp"`
+	Participants []string `json:"participants"`
+}
+
+// ImportReport is the result of an import
+type ImportReport struct {
+	Programs     int      `json:"programs"`
+	Users        int      `json:"users"`
+	Meetings     int      `json:"meetings"`
+	UnknownUsers []string `json:"unknown_users"`
+	Errors       []string `json:"errors"`
+}
+
+// exportState converts the state to the export format
+func (p *Plugin) exportState(state *State) *ExportState {
+	usernames := map[string]string{}
+	username := func(userID string) string {
+		if name, ok := usernames[userID]; ok {
+			return name
+		}
+
+		name := userID
+		if user, err := p.API.GetUser(userID); err == nil {
+			name = user.Username
+		} else {
+			p.API.LogWarn(fmt.Sprintf("Failed to get the user %s to export it: %s", userID, err.Error()))
+		}
+
+		usernames[userID] = name
+		return name
+	}
+	usernamesOf := func(users []string) []string {
+		names := []string{}
+		for _, userID := range users {
+			names = append(names, username(userID))
+		}
+		return names
+	}
+
+	export := &ExportState{Version: exportVersion, Programs: []*ExportProgram{}}
+
+	for _, name := range state.ProgramNames() {
+		program := state.Programs[name]
+		exported := &ExportProgram{
+			Name:        name,
+			Users:       usernamesOf(program.Users),
+			Paused:      []*ExportPause{},
+			OddUserTurn: usernamesOf(program.OddUserTurn),
+			History:     []*ExportMeeting{},
+		}
+
+		for _, userID := range program.Paused {
+			exported.Paused = append(exported.Paused, &ExportPause{
+				Username: username(userID),
+				Until:    program.PausedUntil[userID],
+				Rounds:   program.SkipRounds[userID],
+			})
+		}
+
+		for _, record := range program.History {
+			exported.History = append(exported.History, &ExportMeeting{
+				RoundID:      record.RoundID,
+				Timestamp:    record.Timestamp,
+				Participants: usernamesOf(record.Participants),
+			})
+		}
+
+		export.Programs = append(export.Programs, exported)
+	}
+
+	return export
+}
+
+// encodeExport writes the export in the format
+func encodeExport(export *ExportState, format string) ([]byte, error) {
+	if format != formatCSV {
+		return json.MarshalIndent(export, "", "  ")
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	rows := [][]string{csvHeader}
+
+	for _, program := range export.Programs {
+		for _, username := range program.Users {
+			rows = append(rows, []string{"user", program.Name, "", "", username, ""})
+		}
+
+		for _, pause := range program.Paused {
+			until, rounds := "", ""
+			if pause.Until != 0 {
+				until = strconv.FormatInt(pause.Until, 10)
+			}
+			if pause.Rounds != 0 {
+				rounds = strconv.Itoa(pause.Rounds)
+			}
+			rows = append(rows, []string{"paused", program.Name, "", until, pause.Username, rounds})
+		}
+
+		for _, username := range program.OddUserTurn {
+			rows = append(rows, []string{"odd_turn", program.Name, "", "", username, ""})
+		}
+
+		for _, meeting := range program.History {
+			rows = append(rows, []string{"meeting", program.Name, meeting.RoundID, strconv.FormatInt(meeting.Timestamp, 10), strings.Join(meeting.Participants, " "), ""})
+		}
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// detectFormat returns the format of the file, by its name or its content
+func detectFormat(filename string, data []byte) string {
+	if strings.HasSuffix(strings.ToLower(filename), ".csv") {
+		return formatCSV
+	}
+
+	if strings.HasSuffix(strings.ToLower(filename), ".json") || strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		return formatJSON
+	}
+
+	return formatCSV
+}
+
+// decodeImport reads an export in the format, it returns the errors of the rows that can't be read
+func decodeImport(data []byte, format string) (*ExportState, []string) {
+	if format == formatJSON {
+		export := &ExportState{}
+		if err := json.Unmarshal(data, export); err != nil {
+			return nil, []string{fmt.Sprintf("invalid JSON: %s", err.Error())}
+		}
+
+		if export.Version != exportVersion {
+			return nil, []string{fmt.Sprintf("unsupported version %d", export.Version)}
+		}
+
+		return export, []string{}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = len(csvHeader)
+	export := &ExportState{Version: exportVersion, Programs: []*ExportProgram{}}
+	programs := map[string]*ExportProgram{}
+	errs := []string{}
+
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			errs = append(errs, err.Error())
+			if _, ok := err.(*csv.ParseError); ok {
+				continue
+			}
+			break
+		}
+
+		if line == 1 && row[0] == csvHeader[0] {
+			continue
+		}
+
+		program, ok := programs[row[1]]
+		if !ok {
+			program = &ExportProgram{Name: row[1], Users: []string{}, Paused: []*ExportPause{}, OddUserTurn: []string{}, History: []*ExportMeeting{}}
+			programs[row[1]] = program
+			export.Programs = append(export.Programs, program)
+		}
+
+		timestamp := int64(0)
+		if row[3] != "" {
+			if timestamp, err = strconv.ParseInt(row[3], 10, 64); err != nil {
+				errs = append(errs, fmt.Sprintf("line %d: invalid timestamp %s", line, row[3]))
+				continue
+			}
+		}
+
+		rounds := 0
+		if row[5] != "" {
+			if rounds, err = strconv.Atoi(row[5]); err != nil || rounds < 1 {
+				errs = append(errs, fmt.Sprintf("line %d: invalid rounds %s", line, row[5]))
+				continue
+			}
+		}
+
+		switch row[0] {
+		case "user":
+			program.Users = append(program.Users, row[4])
+		case "paused":
+			program.Paused = append(program.Paused, &ExportPause{Username: row[4], Until: timestamp, Rounds: rounds})
+		case "odd_turn":
+			program.OddUserTurn = append(program.OddUserTurn, row[4])
+		case "meeting":
+			program.History = append(program.History, &ExportMeeting{RoundID: row[2], Timestamp: timestamp, Participants: strings.Fields(row[4])})
+		default:
+			errs = append(errs, fmt.Sprintf("line %d: unknown type %s", line, row[0]))
+		}
+	}
+
+	return export, errs
+}
+
+// importState applies the export to the state, the unknown users are left out
+func (p *Plugin) importState(export *ExportState, mode string) *ImportReport {
+	report := &ImportReport{UnknownUsers: []string{}, Errors: []string{}}
+
+	userIDs := map[string]string{}
+	userID := func(username string) (string, bool) {
+		username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+		if id, ok := userIDs[username]; ok {
+			return id, id != ""
+		}
+
+		user, err := p.API.GetUserByUsername(username)
+		if err != nil {
+			userIDs[username] = ""
+			report.UnknownUsers = append(report.UnknownUsers, username)
+			return "", false
+		}
+
+		userIDs[username] = user.Id
+		return user.Id, true
+	}
+	userIDsOf := func(usernames []string) []string {
+		ids := []string{}
+		for _, username := range usernames {
+			if id, ok := userID(username); ok && !utils.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	// the users are resolved before saving, the state may be saved more than once on conflicts
+	imported := []*Program{}
+	for _, exported := range export.Programs {
+		if err := validateProgramName(exported.Name); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("program %s: %s", exported.Name, err.Error()))
+			continue
+		}
+
+		program := NewProgram(exported.Name)
+		program.Users = userIDsOf(exported.Users)
+		program.OddUserTurn = userIDsOf(exported.OddUserTurn)
+
+		for _, pause := range exported.Paused {
+			id, ok := userID(pause.Username)
+			if !ok || utils.Contains(program.Paused, id) {
+				continue
+			}
+
+			program.Paused = append(program.Paused, id)
+			if pause.Until != 0 {
+				program.PausedUntil[id] = pause.Until
+			}
+			if pause.Rounds > 0 {
+				program.SkipRounds[id] = pause.Rounds
+			}
+		}
+
+		for i, meeting := range exported.History {
+			participants := userIDsOf(meeting.Participants)
+			if len(participants) < 2 {
+				report.Errors = append(report.Errors, fmt.Sprintf("program %s: meeting %d of the round %s has less than two known participants", exported.Name, i+1, meeting.RoundID))
+				continue
+			}
+
+			program.History = append(program.History, &MeetingRecord{
+				RoundID:      meeting.RoundID,
+				Timestamp:    meeting.Timestamp,
+				Participants: participants,
+			})
+		}
+
+		imported = append(imported, program)
+	}
+
+	sort.Strings(report.UnknownUsers)
+
+	created := false
+	err := p.updateState(func(state *State) error {
+		created = false
+		report.Programs, report.Users, report.Meetings = 0, 0, 0
+
+		for _, program := range imported {
+			current, ok := state.Programs[program.Name]
+			if !ok {
+				current = NewProgram(program.Name)
+				state.Programs[program.Name] = current
+				created = true
+			}
+
+			report.Programs++
+			report.Users += len(program.Users)
+			report.Meetings += len(program.History)
+			mergeProgram(current, program.Clone(), mode)
+		}
+		return nil
+	})
+
+	if err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to import the state: %s", err.Error()))
+		report.Errors = append(report.Errors, "failed to save the state")
+		report.Programs, report.Users, report.Meetings = 0, 0, 0
+		return report
+	}
+
+	if created {
+		p.addCronFunc()
+	}
+
+	return report
+}
+
+// mergeProgram applies the imported program to the current one. The meetings already in the
+// history are kept as they are, with their channel and feedback.
+func mergeProgram(current *Program, imported *Program, mode string) {
+	history := History{}
+	if mode == importMerge {
+		history = append(history, current.History...)
+	}
+
+	for _, record := range imported.History {
+		if existing, ok := findMeeting(current.History, record); ok {
+			record = existing
+		}
+
+		if _, ok := findMeeting(history, record); !ok {
+			history = append(history, record)
+		}
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp < history[j].Timestamp
+	})
+	current.History = history
+
+	if mode == importReplace {
+		current.Users = []string{}
+		current.Paused = []string{}
+		current.PausedUntil = map[string]int64{}
+		current.SkipRounds = map[string]int{}
+		current.OddUserTurn = []string{}
+		current.Frequencies = map[string]string{}
+		current.LastPaired = map[string]*PairedRound{}
+	}
+
+	for _, userID := range imported.Users {
+		if !utils.Contains(current.Users, userID) {
+			current.Users = append(current.Users, userID)
+		}
+	}
+
+	for _, userID := range imported.Paused {
+		clearPause(current, userID)
+		current.Paused = append(current.Paused, userID)
+
+		if until, ok := imported.PausedUntil[userID]; ok {
+			if current.PausedUntil == nil {
+				current.PausedUntil = make(map[string]int64)
+			}
+			current.PausedUntil[userID] = until
+		}
+
+		if rounds, ok := imported.SkipRounds[userID]; ok {
+			if current.SkipRounds == nil {
+				current.SkipRounds = make(map[string]int)
+			}
+			current.SkipRounds[userID] = rounds
+		}
+	}
+
+	for _, userID := range imported.OddUserTurn {
+		if !utils.Contains(current.OddUserTurn, userID) {
+			current.OddUserTurn = append(current.OddUserTurn, userID)
+		}
+	}
+}
+
+// findMeeting returns the meeting of the history of the same round, time and participants
+func findMeeting(history History, meeting *MeetingRecord) (*MeetingRecord, bool) {
+	participants := append([]string{}, meeting.Participants...)
+	sort.Strings(participants)
+
+	for _, record := range history {
+		if record.RoundID != meeting.RoundID || record.Timestamp != meeting.Timestamp || len(record.Participants) != len(participants) {
+			continue
+		}
+
+		recordParticipants := append([]string{}, record.Participants...)
+		sort.Strings(recordParticipants)
+
+		if strings.Join(recordParticipants, ",") == strings.Join(participants, ",") {
+			return record, true
+		}
+	}
+
+	return nil, false
+}
+
+// importReportText formats the report of an import
+func importReportText(report *ImportReport) string {
+	lines := []string{fmt.Sprintf("Import finished: %d programs, %d users and %d meetings imported.", report.Programs, report.Users, report.Meetings)}
+
+	if len(report.UnknownUsers) > 0 {
+		lines = append(lines, fmt.Sprintf("Unknown users, left out: %s.", strings.Join(report.UnknownUsers, ", ")))
+	}
+
+	if len(report.Errors) > 0 {
+		lines = append(lines, "Errors:")
+		for _, err := range report.Errors {
+			lines = append(lines, " - "+err)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// sendExport sends the export of the state to the admin in a direct message
+func (p *Plugin) sendExport(userID string, format string) error {
+	data, err := encodeExport(p.exportState(p.state.Get()), format)
+	if err != nil {
+		return err
+	}
+
+	channel, appErr := p.API.GetDirectChannel(p.botUserID, userID)
+	if appErr != nil {
+		return appErr
+	}
+
+	info, appErr := p.API.UploadFile(data, channel.Id, "gather-users-export."+format)
+	if appErr != nil {
+		return appErr
+	}
+
+	_, appErr = p.API.CreatePost(&model.Post{
+		UserId:    p.botUserID,
+		ChannelId: channel.Id,
+		Message:   "Export of the gather plugin.",
+		FileIds:   []string{info.Id},
+	})
+	if appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// MessageHasBeenPosted imports the files the admins send to the bot with the message `import merge`
+// or `import replace`
+func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	fields := strings.Fields(post.Message)
+	if post.UserId == p.botUserID || len(post.FileIds) == 0 || len(fields) == 0 || fields[0] != "import" {
+		return
+	}
+
+	channel, appErr := p.API.GetChannel(post.ChannelId)
+	if appErr != nil || channel.Type != model.CHANNEL_DIRECT || channel.Name != model.GetDMNameFromIds(p.botUserID, post.UserId) {
+		return
+	}
+
+	reply := func(message string) {
+		if _, err := p.API.CreatePost(&model.Post{UserId: p.botUserID, ChannelId: post.ChannelId, RootId: post.Id, Message: message}); err != nil {
+			p.API.LogError(fmt.Sprintf("Failed to reply to the import: %s", err.Error()))
+		}
+	}
+
+	user, appErr := p.API.GetUser(post.UserId)
+	if appErr != nil || !user.IsSystemAdmin() {
+		reply("Only system admins can do this.")
+		return
+	}
+
+	mode := importMerge
+	if len(fields) > 1 {
+		mode = fields[1]
+	}
+
+	if mode != importMerge && mode != importReplace {
+		reply("The import mode must be merge or replace.")
+		return
+	}
+
+	for _, fileID := range post.FileIds {
+		info, appErr := p.API.GetFileInfo(fileID)
+		if appErr != nil {
+			reply(fmt.Sprintf("Failed to read the file: %s", appErr.Error()))
+			continue
+		}
+
+		data, appErr := p.API.GetFile(fileID)
+		if appErr != nil {
+			reply(fmt.Sprintf("Failed to read the file %s: %s", info.Name, appErr.Error()))
+			continue
+		}
+
+		reply(p.importData(data, detectFormat(info.Name, data), mode))
+	}
+}
+
+// importData imports the export in the format and returns the report
+func (p *Plugin) importData(data []byte, format string, mode string) string {
+	export, errs := decodeImport(data, format)
+	if export == nil {
+		return importReportText(&ImportReport{Errors: errs})
+	}
+
+	report := p.importState(export, mode)
+	report.Errors = append(errs, report.Errors...)
+
+	return importReportText(report)
+}
+
+// serveExportAPI exports the state, as JSON unless the format query parameter is csv
+func (p *Plugin) serveExportAPI(req *apiRequest) {
+	if req.r.Method != http.MethodGet {
+		writeError(req.w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	format := req.r.URL.Query().Get("format")
+	data, err := encodeExport(p.exportState(p.state.Get()), format)
+	if err != nil {
+		writeError(req.w, http.StatusInternalServerError, "Failed to export the state.")
+		return
+	}
+
+	contentType := "application/json"
+	if format == formatCSV {
+		contentType = "text/csv"
+	}
+
+	req.w.Header().Set("Content-Type", contentType)
+	req.w.WriteHeader(http.StatusOK)
+	if _, err := req.w.Write(data); err != nil {
+		p.API.LogError(fmt.Sprintf("Failed to write the export: %s", err.Error()))
+	}
+}
+
+// serveImportAPI imports the body, the mode and format query parameters default to merge and the
+// format of the body
+func (p *Plugin) serveImportAPI(req *apiRequest) {
+	if req.r.Method != http.MethodPost {
+		writeError(req.w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+
+	if !p.requireAdmin(req) {
+		return
+	}
+
+	mode := req.r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = importMerge
+	}
+
+	if mode != importMerge && mode != importReplace {
+		writeError(req.w, http.StatusBadRequest, "The import mode must be merge or replace.")
+		return
+	}
+
+	data, err := ioutil.ReadAll(req.r.Body)
+	if err != nil {
+		writeError(req.w, http.StatusBadRequest, "Failed to read the body.")
+		return
+	}
+
+	format := req.r.URL.Query().Get("format")
+	if format != formatJSON && format != formatCSV {
+		format = detectFormat("", data)
+	}
+
+	export, errs := decodeImport(data, format)
+	if export == nil {
+		writeJSON(req.w, http.StatusBadRequest, &ImportReport{UnknownUsers: []string{}, Errors: errs})
+		return
+	}
+
+	report := p.importState(export, mode)
+	report.Errors = append(errs, report.Errors...)
+
+	writeJSON(req.w, http.StatusOK, report)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin/plugintest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTransferTestPlugin() *Plugin {
+	p := newTestPlugin(newMemoryKV())
+	p.API.(*plugintest.API).On("GetUserByUsername", mock.AnythingOfType("string")).Return(func(username string) *model.User {
+		if username == "ghost" {
+			return nil
+		}
+		return &model.User{Id: username, Username: username}
+	}, func(username string) *model.AppError {
+		if username == "ghost" {
+			return model.NewAppError("GetUserByUsername", "not_found", nil, "", http.StatusNotFound)
+		}
+		return nil
+	})
+	p.API.(*plugintest.API).On("LogWarn", mock.Anything).Maybe()
+
+	return p
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	source := newTransferTestPlugin()
+	source.updateState(func(state *State) error {
+		program := state.Programs[defaultProgram]
+		program.Users = []string{"alice", "bob", "carol"}
+		program.Paused = []string{"carol", "bob"}
+		program.PausedUntil = map[string]int64{"carol": 2000}
+		program.SkipRounds = map[string]int{"bob": 2}
+		program.OddUserTurn = []string{"bob", "alice"}
+		program.History = History{{RoundID: "r1", Timestamp: 10, Participants: []string{"alice", "bob"}}}
+
+		state.Programs["sales"] = NewProgram("sales")
+		state.Programs["sales"].Users = []string{"alice"}
+		return nil
+	})
+
+	for _, format := range []string{formatJSON, formatCSV} {
+		data, err := encodeExport(source.exportState(source.state.Get()), format)
+		assert.Nil(err)
+		assert.Equal(format, detectFormat("", data))
+
+		target := newTransferTestPlugin()
+		assert.Equal("Import finished: 2 programs, 4 users and 1 meetings imported.", target.importData(data, format, importReplace))
+
+		program, _ := target.getProgram(defaultProgram)
+		assert.Equal([]string{"alice", "bob", "carol"}, program.Users, format)
+		assert.Equal([]string{"carol", "bob"}, program.Paused)
+		assert.Equal(int64(2000), program.PausedUntil["carol"])
+		assert.Equal(map[string]int{"bob": 2}, program.SkipRounds, format)
+		assert.Equal([]string{"bob", "alice"}, program.OddUserTurn)
+		assert.Len(program.History, 1)
+		assert.Equal([]string{"alice", "bob"}, program.History[0].Participants)
+
+		sales, ok := target.getProgram("sales")
+		assert.True(ok)
+		assert.Equal([]string{"alice"}, sales.Users)
+	}
+}
+
+func TestImportModesAndReport(t *testing.T) {
+	assert := assert.New(t)
+	p := newTransferTestPlugin()
+	p.updateState(func(state *State) error {
+		program := state.Programs[defaultProgram]
+		program.Users = []string{"alice", "bob"}
+		program.History = History{{RoundID: "r1", Timestamp: 10, Participants: []string{"alice", "bob"}, ChannelID: "channel", Feedback: map[string]*MeetingFeedback{"alice": {Status: feedbackMet}}}}
+		return nil
+	})
+
+	csv := strings.Join([]string{
+		"type,program,round_id,timestamp,usernames,rounds",
+		"user,default,,,@carol,",
+		"user,default,,,ghost,",
+		"meeting,default,r1,10,bob alice,",
+		"meeting,default,r2,20,carol ghost,",
+		"meeting,default,r3,abc,carol alice,",
+		"meeting,Bad Name,r3,30,carol alice,",
+		"paused,default,,,carol,0",
+	}, "\n")
+
+	report := p.importData([]byte(csv), formatCSV, importMerge)
+	assert.Contains(report, "Import finished: 1 programs, 1 users and 1 meetings imported.")
+	assert.Contains(report, "Unknown users, left out: ghost.")
+	assert.Contains(report, " - line 6: invalid timestamp abc")
+	assert.Contains(report, " - program default: meeting 2 of the round r2 has less than two known participants")
+	assert.Contains(report, " - program Bad Name: ")
+	assert.Contains(report, " - line 8: invalid rounds 0")
+
+	// the meeting already in the history keeps its channel and feedback
+	program, _ := p.getProgram(defaultProgram)
+	assert.Equal([]string{"alice", "bob", "carol"}, program.Users)
+	assert.Len(program.History, 1)
+	assert.Equal("channel", program.History[0].ChannelID)
+	assert.NotNil(program.History[0].Feedback["alice"])
+
+	// replacing drops the settings of the users left out
+	p.updateProgram(defaultProgram, func(program *Program) error {
+		program.Paused = []string{"bob"}
+		program.SkipRounds = map[string]int{"bob": 1}
+		program.Frequencies = map[string]string{"bob": frequencyMonthly}
+		program.LastPaired = map[string]*PairedRound{"bob": {RoundID: "r1", Timestamp: 10}}
+		return nil
+	})
+
+	p.importData([]byte("type,program,round_id,timestamp,usernames,rounds\nuser,default,,,dave,\n"), formatCSV, importReplace)
+	program, _ = p.getProgram(defaultProgram)
+	assert.Equal([]string{"dave"}, program.Users)
+	assert.Empty(program.History)
+	assert.Empty(program.Paused)
+	assert.Empty(program.SkipRounds)
+	assert.Empty(program.Frequencies)
+	assert.Empty(program.LastPaired)
+}
+
+func TestExportImportAPI(t *testing.T) {
+	assert := assert.New(t)
+	p := newTransferTestPlugin()
+	p.addUser(defaultProgram, "alice")
+
+	request := func(userID string, method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Mattermost-User-Id", userID)
+		p.ServeHTTP(nil, w, r)
+		return w
+	}
+
+	assert.Equal(http.StatusForbidden, request("alice", http.MethodGet, "/api/v1/export", "").Code)
+
+	w := request("admin", http.MethodGet, "/api/v1/export?format=csv", "")
+	assert.Equal("text/csv", w.Header().Get("Content-Type"))
+	assert.Equal("type,program,round_id,timestamp,usernames,rounds\nuser,default,,,alice,\n", w.Body.String())
+
+	assert.Equal(http.StatusBadRequest, request("admin", http.MethodPost, "/api/v1/import?mode=overwrite", "").Code)
+	assert.Equal(http.StatusBadRequest, request("admin", http.MethodPost, "/api/v1/import", `{"version": 2}`).Code)
+
+	w = request("admin", http.MethodPost, "/api/v1/import?mode=replace", `{"version": 1, "programs": [{"name": "default", "users": ["bob", "ghost"]}]}`)
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`{"programs": 1, "users": 1, "meetings": 0, "unknown_users": ["ghost"], "errors": []}`, w.Body.String())
+
+	program, _ := p.getProgram(defaultProgram)
+	assert.Equal([]string{"bob"}, program.Users)
+}
@@ -34,3 +34,14 @@ func Prepend(data []string, item string) []string {
 	data = append([]string{item}, data...)
 	return data
   }
+
+func Intersect(slice []string, other []string) []string {
+	result := []string{}
+	for _, v := range slice {
+		if Contains(other, v) {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}